@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+type identityKey struct{}
+
+// identity is who sends a request according to its token. The token is verified the first time someone asks and the
+// outcome is kept for the rest of the request, so middlewares and handlers share a single verification.
+type identity struct {
+	mu       sync.Mutex
+	verified bool
+	uid      uint32
+	err      error
+}
+
+// Function WithIdentity returns the request with a place to keep who sends it, every request gets its own even when
+// its context comes from another request, as the operations of a batch do
+func WithIdentity(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), identityKey{}, &identity{}))
+}
+
+// Function identityOf returns the identity WithIdentity gave to the request, nil when it has none
+func identityOf(r *http.Request) *identity {
+	id, _ := r.Context().Value(identityKey{}).(*identity)
+	return id
+}
+
+// Function VerifiedUserID returns the user behind the token of the request when it was already verified, it never
+// verifies the token itself
+func VerifiedUserID(r *http.Request) (uint32, bool) {
+	id := identityOf(r)
+	if id == nil {
+		return 0, false
+	}
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	return id.uid, id.verified && id.err == nil && id.uid != 0
+}
+
+// Function verify returns the user the token of the request was created for, verifying the token the first time only
+func (id *identity) verify(r *http.Request, spanName string) (uint32, error) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	if !id.verified {
+		id.uid, id.err = verifyRequest(r, spanName)
+		id.verified = true
+	}
+	return id.uid, id.err
+}
+
+// Function verifyRequest verifies the token a request carries and returns the user it was created for
+func verifyRequest(r *http.Request, spanName string) (uint32, error) {
+	token, err := parseToken(r, spanName)
+	if err != nil {
+		return 0, err
+	}
+	if !token.Valid {
+		return 0, fmt.Errorf("invalid token")
+	}
+	return userID(token)
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIdentityVerifiesTheTokenOnce(t *testing.T) {
+	t.Setenv("API_SECRET", "test-secret")
+	token, err := CreateToken(7)
+	if err != nil {
+		t.Fatalf("cannot create a token: %v", err)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	r = WithIdentity(r)
+
+	if _, ok := VerifiedUserID(r); ok {
+		t.Fatal("the token is reported verified before anyone verified it")
+	}
+	if err := TokenValid(r); err != nil {
+		t.Fatalf("TokenValid: %v", err)
+	}
+
+	// A token checked again with another secret would be refused, the request keeps what the first check found
+	t.Setenv("API_SECRET", "another-secret")
+	uid, err := ExtractTokenID(r)
+	if err != nil || uid != 7 {
+		t.Fatalf("ExtractTokenID: got %d, %v, want 7", uid, err)
+	}
+	if uid, ok := VerifiedUserID(r); !ok || uid != 7 {
+		t.Fatalf("VerifiedUserID: got %d, %v, want 7", uid, ok)
+	}
+
+	// Another request sharing the context gets its own identity
+	other := WithIdentity(r)
+	if _, err := ExtractTokenID(other); err == nil {
+		t.Fatal("a new identity reused the verification of another request")
+	}
+}
+
+func TestIdentityOfAnInvalidToken(t *testing.T) {
+	t.Setenv("API_SECRET", "test-secret")
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer not-a-token")
+	r = WithIdentity(r)
+
+	if err := TokenValid(r); err == nil {
+		t.Fatal("an invalid token was accepted")
+	}
+	if _, ok := VerifiedUserID(r); ok {
+		t.Fatal("an invalid token is reported as a verified user")
+	}
+}
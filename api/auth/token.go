@@ -1,16 +1,17 @@
 package auth
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/tracing"
 	"github.com/dgrijalva/jwt-go"
+	"go.opentelemetry.io/otel/codes"
 )
 
 func CreateToken(user_id uint32) (string, error) {
@@ -18,27 +19,13 @@ func CreateToken(user_id uint32) (string, error) {
 	claims["authorized"] = true
 	claims["user_id"] = user_id
 	claims["exp"] = time.Now().Add(time.Hour * 1).Unix() // Token to expire after an hour
-	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(os.Getenv("API_SECRET")))
 }
 
 func TokenValid(responce *http.Request) error {
-	tokenString := ExtractToken(responce)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
-
-	if err != nil {
-		return err
-	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		Pretty(claims)
-	}
-	return nil
+	_, err := tokenID(responce, "auth.TokenValid")
+	return err
 }
 
 func ExtractToken(responce *http.Request) string {
@@ -49,29 +36,41 @@ func ExtractToken(responce *http.Request) string {
 	}
 
 	bearerToken := responce.Header.Get("Authorization")
-	if len(strings.Split(bearerToken, "")) == 2 {
-		return strings.Split(bearerToken, "")[1]
+	if len(strings.Split(bearerToken, " ")) == 2 {
+		return strings.Split(bearerToken, " ")[1]
 	}
 
 	return ""
 }
 
 func ExtractTokenID(r *http.Request) (uint32, error) {
-	tokenString := ExtractToken(r)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
+	return tokenID(r, "auth.ExtractTokenID")
+}
+
+// Function tokenID returns the user the token of the request was created for, reusing what an earlier call found for the
+// same request when it has an identity
+func tokenID(r *http.Request, spanName string) (uint32, error) {
+	if id := identityOf(r); id != nil {
+		return id.verify(r, spanName)
+	}
+	return verifyRequest(r, spanName)
+}
 
+// Function TokenID verifies a bare token, as gRPC clients send it in their metadata, and returns the user it was
+// created for
+func TokenID(ctx context.Context, tokenString string) (uint32, error) {
+	token, err := verifyToken(ctx, tokenString, "auth.TokenID")
 	if err != nil {
 		return 0, err
 	}
+	return userID(token)
+}
 
+// Function userID reads the user a verified token was created for
+func userID(token *jwt.Token) (uint32, error) {
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if ok && token.Valid {
-		uid, err := strconv.ParseUint(fmt.Sprintf("%.of", claims["user_id"]), 10, 32)
+		uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["user_id"]), 10, 32)
 		if err != nil {
 			return 0, err
 		}
@@ -81,11 +80,26 @@ func ExtractTokenID(r *http.Request) (uint32, error) {
 	return 0, nil
 }
 
-// function Pretty displays the claims licely in the terminal
-func Pretty(data interface{}) {
-	b, err := json.MarshalIndent(data, "", "")
+// Function parseToken verifies the token a request carries
+func parseToken(r *http.Request, spanName string) (*jwt.Token, error) {
+	return verifyToken(r.Context(), ExtractToken(r), spanName)
+}
+
+// Function verifyToken checks the signature of a token inside a span named after the caller
+func verifyToken(ctx context.Context, tokenString, spanName string) (*jwt.Token, error) {
+	_, span := tracing.Tracer().Start(ctx, spanName)
+	defer span.End()
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(os.Getenv("API_SECRET")), nil
+	})
 	if err != nil {
-		log.Println(err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid token")
+		return nil, err
 	}
-	fmt.Println(string(b))
+	return token, nil
 }
@@ -1,18 +1,95 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/graph"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/health"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/idempotency"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/metrics"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/middlewares"
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/notify"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/ratelimit"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/spam"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/tracing"
 	"github.com/gorilla/mux"
+	"github.com/graphql-go/graphql"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
+// DrainDelay is how long the server keeps serving, with readiness failing, before it stops accepting connections.
+// It gives load balancers time to notice and take the instance out of rotation.
+var DrainDelay = 5 * time.Second
+
+// DefaultMaxBodyBytes limits request bodies when MaxBodyBytes is not set
+const DefaultMaxBodyBytes = 1 << 20
+
+// DefaultCompressionMinBytes is the smallest body compressed when CompressionMinBytes is not set
+const DefaultCompressionMinBytes = 1024
+
+// LegacyDeprecatedSince and LegacySunset date the deprecation of the unversioned aliases of the v1 routes when
+// the server is not configured with other dates
+var (
+	LegacyDeprecatedSince = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	LegacySunset          = LegacyDeprecatedSince.AddDate(0, 6, 0)
+)
+
+// ShutdownTimeout is how long in-flight requests get to finish once the server stops accepting connections
+var ShutdownTimeout = 15 * time.Second
+
 type Server struct {
-	DB     *gorm.DB
-	Router *mux.Router
+	DB       *gorm.DB
+	Router   *mux.Router
+	Spam     spam.Scorer
+	Notifier *notify.Notifier
+	SQLLog   logger.Interface
+	Health   *health.Checker
+	Limiter  *ratelimit.Limiter
+	// Idempotency replays the first response to an Idempotency-Key on the POST routes that create resources
+	Idempotency *idempotency.Guard
+
+	// CORS, Security, MaxBodyBytes and CompressionMinBytes configure the middlewares every request goes through
+	CORS                middlewares.CORSConfig
+	Security            middlewares.SecurityConfig
+	MaxBodyBytes        int64
+	CompressionMinBytes int
+
+	// Deprecation dates the unversioned aliases of the v1 routes
+	Deprecation middlewares.DeprecationConfig
+
+	// GraphQLLimits bound the depth and complexity of GraphQL queries, graph.DefaultLimits apply to the limits not set.
+	// GraphQLPlayground serves GraphiQL at /graphql/playground, it is meant for development only.
+	GraphQLLimits     graph.Limits
+	GraphQLPlayground bool
+
+	// BatchMaxOperations limits how many operations a batch may hold, DefaultBatchMaxOperations when not set
+	BatchMaxOperations int
+
+	// GRPCAddr is the address the gRPC API listens on next to the HTTP server, it is not served when empty
+	GRPCAddr string
+
+	graphql    graphql.Schema
+	grpcHealth *grpchealth.Server
+	spec       map[string]interface{}
+	legacy     *mux.Router
+	stopping   chan struct{}
 }
 
 func (server *Server) IntializeDB(DBDriver, DBUser, DBPassword, DBPort, DBHost, DBName string) {
@@ -20,23 +97,193 @@ func (server *Server) IntializeDB(DBDriver, DBUser, DBPassword, DBPort, DBHost,
 
 	if DBDriver == "postgres" {
 		DBURL := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s", DBHost, DBPort, DBUser, DBName, DBPassword)
-		server.DB, err = gorm.Open(DBDriver, DBURL)
+		server.DB, err = gorm.Open(models.Postgres(postgres.Config{DSN: DBURL}), &gorm.Config{Logger: server.SQLLog})
 		if err != nil {
-			fmt.Printf("Cannot connect to the %s database", DBDriver)
-			log.Fatal("This is the error:", err)
-		} else {
-			fmt.Printf("We are connected to the %s database", DBDriver)
+			slog.Error("cannot connect to the database", "driver", DBDriver, "error", err.Error())
+			os.Exit(1)
 		}
+		slog.Info("connected to the database", "driver", DBDriver)
+
+		err = metrics.InstrumentDB(server.DB)
+		if err != nil {
+			slog.Error("cannot instrument the database", "error", err.Error())
+			os.Exit(1)
+		}
+		err = server.DB.Use(tracing.GormPlugin{})
+		if err != nil {
+			slog.Error("cannot trace the database", "error", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	err = server.DB.AutoMigrate(models.Tables()...) //DB Migration
+	if err != nil {
+		slog.Error("cannot migrate the database", "error", err.Error())
+		os.Exit(1)
 	}
 
-	server.DB.Debug().AutoMigrate(&models.User{}, &models.Post{}) //DB Migration
+	server.registerHealthChecks()
+
+	server.graphql, err = server.graphqlSchema()
+	if err != nil {
+		slog.Error("cannot build the GraphQL schema", "error", err.Error())
+		os.Exit(1)
+	}
 
 	server.Router = mux.NewRouter()
 
 	server.initializeRoutes()
+	server.buildSpec()
+}
+
+// Function db returns the database session for a request, its queries join the request trace and log with its request id
+func (server *Server) db(r *http.Request) *gorm.DB {
+	// The operations of an atomic batch share its transaction
+	if tx := batchOf(r).transaction(); tx != nil {
+		return tx.WithContext(r.Context())
+	}
+	return server.DB.WithContext(r.Context())
 }
 
+// Function expectedVersion checks the If-Match header of a write against the current version of the resource. It returns
+// the version the write must still find, or 0 when the client sent no precondition.
+func expectedVersion(r *http.Request, current uint64) (uint64, error) {
+	versions, err := responses.IfMatch(r)
+	if err != nil || versions == nil {
+		return 0, err
+	}
+	for _, version := range versions {
+		if version == current {
+			return current, nil
+		}
+	}
+	return 0, models.ErrStaleVersion
+}
+
+// viewerKey keys the viewers of a request in its context
+type viewerKey struct{}
+
+// viewers keeps who the responses of a request are written for, so the role of each viewer is looked up once however
+// many responses, resolvers or batch operations ask for it
+type viewers struct {
+	mu   sync.Mutex
+	byID map[uint32]dto.Viewer
+}
+
+// Function setMiddlewareViewer gives every request a place to keep its viewers. The operations of a batch share the one
+// of the batch, an operation sent with its own token is kept apart by its user id.
+func setMiddlewareViewer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(viewerKey{}).(*viewers); !ok {
+			r = r.WithContext(context.WithValue(r.Context(), viewerKey{}, &viewers{byID: map[uint32]dto.Viewer{}}))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Function viewerID returns the id of the user making the request, or 0 when the request is anonymous
+func viewerID(r *http.Request) uint32 {
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		return 0
+	}
+	return uid
+}
+
+// Function viewer returns who the response is written for, administrators are looked up so they get the admin view
+func (server *Server) viewer(r *http.Request) dto.Viewer {
+	uid := viewerID(r)
+	if uid == 0 {
+		return dto.Viewer{}
+	}
+	cache, ok := r.Context().Value(viewerKey{}).(*viewers)
+	if !ok {
+		return server.lookupViewer(r, uid)
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	viewer, ok := cache.byID[uid]
+	if !ok {
+		viewer = server.lookupViewer(r, uid)
+		cache.byID[uid] = viewer
+	}
+	return viewer
+}
+
+// Function lookupViewer reads the role of the user uid making the request
+func (server *Server) lookupViewer(r *http.Request, uid uint32) dto.Viewer {
+	user := models.User{}
+	err := server.db(r).Model(models.User{}).Select("role").Where("id = ?", uid).Take(&user).Error
+	return dto.Viewer{ID: uid, Admin: err == nil && user.Role == models.RoleAdmin}
+}
+
+// Function Run serves HTTP, and gRPC when GRPCAddr is set, until the process receives SIGINT or SIGTERM, then fails
+// readiness, waits DrainDelay and shuts down gracefully, giving in-flight requests and calls ShutdownTimeout to finish
 func (server *Server) Run(addr string) {
-	fmt.Println("Listening to port 8080")
-	log.Fatal(http.ListenAndServe(addr, server.Router))
+	server.stopping = make(chan struct{})
+	httpServer := &http.Server{Addr: addr, Handler: server.Router}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 2)
+	go func() {
+		slog.Info("listening", "addr", addr)
+		errs <- httpServer.ListenAndServe()
+	}()
+
+	var grpcServer *grpc.Server
+	if server.GRPCAddr != "" {
+		listener, err := net.Listen("tcp", server.GRPCAddr)
+		if err != nil {
+			slog.Error("cannot listen for gRPC", "addr", server.GRPCAddr, "error", err.Error())
+			return
+		}
+		grpcServer = server.NewGRPCServer()
+		go func() {
+			slog.Info("listening for gRPC", "addr", server.GRPCAddr)
+			errs <- grpcServer.Serve(listener)
+		}()
+	}
+
+	select {
+	case err := <-errs:
+		slog.Error("server stopped", "error", err.Error())
+		return
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down, draining connections", "drain", DrainDelay.String())
+	server.Health.Shutdown()
+	if grpcServer != nil {
+		server.grpcHealth.Shutdown()
+	}
+	time.Sleep(DrainDelay)
+
+	// Streams never finish on their own, they are told to end before waiting for the other requests
+	close(server.stopping)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	grpcStopped := make(chan struct{})
+	go func() {
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		close(grpcStopped)
+	}()
+	err := httpServer.Shutdown(shutdownCtx)
+	select {
+	case <-grpcStopped:
+	case <-shutdownCtx.Done():
+		// Calls still running at the timeout are cut off like HTTP requests are
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
+	}
+	if err != nil {
+		slog.Error("cannot shut down gracefully", "error", err.Error())
+		return
+	}
+	slog.Info("server stopped")
 }
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/gorilla/mux"
+)
+
+// testSecret signs the tokens of the tests
+const testSecret = "test-secret"
+
+// Function testServer builds a server with every route on a fake database answered by handle, configure sets the
+// fields the routes depend on
+func testServer(t *testing.T, handle fakeHandler, configure ...func(*Server)) *Server {
+	t.Helper()
+	t.Setenv("API_SECRET", testSecret)
+	server := &Server{DB: fakeDB(t, handle)}
+	for _, fn := range configure {
+		fn(server)
+	}
+
+	var err error
+	server.graphql, err = server.graphqlSchema()
+	if err != nil {
+		t.Fatalf("cannot build the GraphQL schema: %v", err)
+	}
+	server.Router = mux.NewRouter()
+	server.initializeRoutes()
+	server.buildSpec()
+	return server
+}
+
+// Function tokenFor returns a bearer token for the user with the given id
+func tokenFor(t *testing.T, uid uint32) string {
+	t.Helper()
+	token, err := auth.CreateToken(uid)
+	if err != nil {
+		t.Fatalf("cannot create a token: %v", err)
+	}
+	return token
+}
+
+// Function serve sends a request through the router of server, authenticated when token is not empty
+func serve(server *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, path, reader)
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	server.Router.ServeHTTP(w, r)
+	return w
+}
+
+// Function roleLookups counts the queries that read the role of a viewer
+func roleLookups(tables *fakeTables) int {
+	lookups := 0
+	for _, statement := range tables.statements {
+		if strings.HasPrefix(statement, `SELECT "role" FROM "users"`) {
+			lookups++
+		}
+	}
+	return lookups
+}
+
+func TestViewerRoleIsLookedUpOnce(t *testing.T) {
+	tables := blogTables()
+	tables.rows["users"].rows[0][3] = "admin"
+	server := testServer(t, tables.handle)
+
+	// User 2 gets the admin view of the author, the fake users table answers every lookup with the admin
+	w := serve(server, http.MethodGet, "/v1/posts?fields=title,author.email", tokenFor(t, 2), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "ada@example.com") {
+		t.Errorf("an admin does not see the email of the author: %s", w.Body)
+	}
+	if lookups := roleLookups(tables); lookups != 1 {
+		t.Errorf("the role of the viewer was looked up %d times, want once", lookups)
+	}
+
+	tables.statements = nil
+	w = serve(server, http.MethodPost, "/v1/batch", tokenFor(t, 2), `{"operations": [
+		{"method": "GET", "path": "/v1/posts/1"},
+		{"method": "GET", "path": "/v1/users/1"},
+		{"method": "GET", "path": "/v1/posts"}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("batch: got %d, want 200: %s", w.Code, w.Body)
+	}
+	if lookups := roleLookups(tables); lookups != 1 {
+		t.Errorf("the role of the viewer was looked up %d times in a batch, want once", lookups)
+	}
+}
@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/idempotency"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
+	"gorm.io/gorm"
+)
+
+// DefaultBatchMaxOperations limits the operations of a batch when BatchMaxOperations is not set
+const DefaultBatchMaxOperations = 100
+
+// batchHeaders are the response headers of an operation a batch passes on, the others are the same for every response
+var batchHeaders = []string{"Location", "ETag", "Entity", "Retry-After"}
+
+// batch is the batch a request runs in. The operations of an atomic batch share its transaction, and what they do
+// outside the database waits until it is committed.
+type batch struct {
+	mu    sync.Mutex
+	tx    *gorm.DB
+	done  bool
+	after []func()
+}
+
+type batchKey struct{}
+
+// Function batchOf returns the batch a request runs in, nil for requests sent on their own
+func batchOf(r *http.Request) *batch {
+	batch, _ := r.Context().Value(batchKey{}).(*batch)
+	return batch
+}
+
+// Function transaction returns the transaction of an atomic batch while it is open
+func (batch *batch) transaction() *gorm.DB {
+	if batch == nil {
+		return nil
+	}
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	if batch.done {
+		return nil
+	}
+	return batch.tx
+}
+
+// Function afterCommit runs fn once the changes of the request are committed: right away, or when the atomic batch the
+// request runs in commits. fn is dropped when the batch is rolled back.
+func (server *Server) afterCommit(r *http.Request, fn func()) {
+	batch := batchOf(r)
+	if batch.transaction() == nil {
+		fn()
+		return
+	}
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	batch.after = append(batch.after, fn)
+}
+
+// errBatchFailed rolls back an atomic batch once one of its operations failed
+var errBatchFailed = errors.New("a batch operation failed")
+
+// Function Batch runs several requests in one, each through the router with its own authentication, rate limits and
+// validation. Results are answered with 200 in the order of the operations, whatever their own status.
+func (server *Server) Batch(w http.ResponseWriter, r *http.Request) {
+	if batchOf(r) != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("nested_batch", "A batch cannot contain another batch"))
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	request := dto.BatchRequest{}
+	err = validation.Decode(body, &request)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	err = server.validateBatch(request)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	current := &batch{}
+	ctx := context.WithValue(r.Context(), batchKey{}, current)
+	response := dto.BatchResponse{Atomic: request.Atomic, Committed: true, Results: make([]dto.BatchResult, len(request.Operations))}
+
+	if !request.Atomic {
+		for i, operation := range request.Operations {
+			response.Results[i] = server.runOperation(ctx, r, operation)
+		}
+		responses.JSON(w, http.StatusOK, response)
+		return
+	}
+
+	ran := 0
+	err = server.db(r).Transaction(func(tx *gorm.DB) error {
+		current.tx = tx
+		for _, operation := range request.Operations {
+			response.Results[ran] = server.runOperation(ctx, r, operation)
+			ran++
+			if response.Results[ran-1].Status >= http.StatusBadRequest {
+				return errBatchFailed
+			}
+		}
+		return nil
+	})
+	current.mu.Lock()
+	current.done = true
+	after := current.after
+	current.mu.Unlock()
+
+	if err != nil && !errors.Is(err, errBatchFailed) {
+		// The transaction could not be opened or committed, none of the operations took effect
+		responses.ERROR(w, http.StatusInternalServerError, apperror.Internal("batch_not_committed", "The batch could not be committed, none of its operations took effect"))
+		return
+	}
+	if err != nil {
+		response.Committed = false
+		skipped := problem(apperror.New(http.StatusFailedDependency, "batch_aborted", "The operation was not run, an earlier operation of the atomic batch failed"))
+		for i := ran; i < len(request.Operations); i++ {
+			response.Results[i] = skipped
+		}
+		responses.JSON(w, http.StatusOK, response)
+		return
+	}
+	for _, fn := range after {
+		fn()
+	}
+	responses.JSON(w, http.StatusOK, response)
+}
+
+// Function validateBatch checks the size of a batch and the operations it holds
+func (server *Server) validateBatch(request dto.BatchRequest) error {
+	err := validation.Struct(request)
+	if err != nil {
+		return err
+	}
+
+	maxOperations := server.BatchMaxOperations
+	if maxOperations <= 0 {
+		maxOperations = DefaultBatchMaxOperations
+	}
+	if len(request.Operations) > maxOperations {
+		return apperror.Validation("batch_too_large", fmt.Sprintf("A batch holds at most %d operations", maxOperations), validation.FieldError{Field: "operations", Message: fmt.Sprintf("operations must be at most %d", maxOperations)})
+	}
+
+	fields := validation.Errors{}
+	for i, operation := range request.Operations {
+		name := fmt.Sprintf("operations[%d]", i)
+		if err := validation.Struct(operation); err != nil {
+			for _, field := range err.(validation.Errors) {
+				fields = append(fields, validation.FieldError{Field: name + "." + field.Field, Message: field.Message})
+			}
+			continue
+		}
+		target, err := url.Parse(operation.Path)
+		if err != nil || target.IsAbs() || target.Host != "" || !strings.HasPrefix(target.Path, "/") {
+			fields = append(fields, validation.FieldError{Field: name + ".path", Message: "path must be a path of this API, e.g. /v1/posts/1"})
+		}
+		// A key saved for an operation that is rolled back would replay a change that never happened
+		if _, ok := header(operation.Headers, idempotency.Header); ok && request.Atomic {
+			fields = append(fields, validation.FieldError{Field: name + ".headers", Message: "operations of an atomic batch cannot have an Idempotency-Key, send it with the batch"})
+		}
+	}
+	if len(fields) > 0 {
+		return apperror.Validation("validation_failed", "The request is invalid", fields...)
+	}
+	return nil
+}
+
+// Function runOperation sends an operation through the router like a request of the client that sent the batch
+func (server *Server) runOperation(ctx context.Context, r *http.Request, operation dto.BatchOperation) dto.BatchResult {
+	sub, err := http.NewRequestWithContext(ctx, operation.Method, operation.Path, bytes.NewReader(operation.Body))
+	if err != nil {
+		return problem(apperror.BadRequest("invalid_operation", "The operation is not a valid request"))
+	}
+	sub.RemoteAddr = r.RemoteAddr
+	sub.Host = r.Host
+	for _, name := range []string{"Authorization", "X-Forwarded-For", "Accept-Language"} {
+		if value := r.Header.Get(name); value != "" {
+			sub.Header.Set(name, value)
+		}
+	}
+	if len(operation.Body) > 0 {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range operation.Headers {
+		sub.Header.Set(name, value)
+	}
+
+	recorder := newBatchRecorder()
+	server.Router.ServeHTTP(recorder, sub)
+	return recorder.result()
+}
+
+// Function header looks up a header of an operation, whatever the case of its name
+func header(headers map[string]string, name string) (string, bool) {
+	for key, value := range headers {
+		if http.CanonicalHeaderKey(key) == name {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Function problem is the result of an operation that failed before or instead of reaching the router
+func problem(err *apperror.Error) dto.BatchResult {
+	recorder := newBatchRecorder()
+	responses.ERROR(recorder, err.Status, err)
+	return recorder.result()
+}
+
+// batchRecorder keeps the response to an operation
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBatchRecorder() *batchRecorder {
+	return &batchRecorder{header: http.Header{}}
+}
+
+func (rec *batchRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *batchRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *batchRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
+
+// Function result turns the recorded response into the result of its operation
+func (rec *batchRecorder) result() dto.BatchResult {
+	result := dto.BatchResult{Status: rec.status}
+	if result.Status == 0 {
+		result.Status = http.StatusOK
+	}
+	for _, name := range batchHeaders {
+		if value := rec.header.Get(name); value != "" {
+			if result.Headers == nil {
+				result.Headers = map[string]string{}
+			}
+			result.Headers[name] = value
+		}
+	}
+
+	body := bytes.TrimSpace(rec.body.Bytes())
+	if len(body) == 0 {
+		return result
+	}
+	if strings.Contains(rec.header.Get("Content-Type"), "json") && json.Valid(body) {
+		result.Body = body
+		return result
+	}
+	result.Body, _ = json.Marshal(string(body))
+	return result
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+)
+
+// Function runBatch sends a batch with the token of user 1 and decodes its response
+func runBatch(t *testing.T, server *Server, body string) dto.BatchResponse {
+	t.Helper()
+	w := serve(server, http.MethodPost, "/v1/batch", tokenFor(t, 1), body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200: %s", w.Code, w.Body)
+	}
+	response := dto.BatchResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("cannot decode %s: %v", w.Body, err)
+	}
+	return response
+}
+
+// Function statuses lists the status of every result of a batch
+func statuses(response dto.BatchResponse) []int {
+	statuses := make([]int, len(response.Results))
+	for i, result := range response.Results {
+		statuses[i] = result.Status
+	}
+	return statuses
+}
+
+// Function updateDelete is a batch where user 1 updates their post, user 2 fails to update it and user 1 deletes it
+func updateDelete(t *testing.T, atomic bool) string {
+	atomicity := "false"
+	if atomic {
+		atomicity = "true"
+	}
+	return `{"atomic": ` + atomicity + `, "operations": [
+		{"method": "PUT", "path": "/v1/posts/1", "body": {"title": "New title", "content": "New content", "author_id": 1}},
+		{"method": "PUT", "path": "/v1/posts/1", "headers": {"Authorization": "Bearer ` + tokenFor(t, 2) + `"}, "body": {"title": "Stolen", "content": "Stolen", "author_id": 2}},
+		{"method": "DELETE", "path": "/v1/posts/1"}
+	]}`
+}
+
+func TestAtomicBatchIsRolledBackAtTheFirstFailure(t *testing.T) {
+	tables := blogTables()
+	server := testServer(t, tables.handle)
+
+	response := runBatch(t, server, updateDelete(t, true))
+	if !response.Atomic || response.Committed {
+		t.Errorf("got atomic %v and committed %v, want an atomic batch that was not committed", response.Atomic, response.Committed)
+	}
+	if got := statuses(response); len(got) != 3 || got[0] != http.StatusOK || got[1] != http.StatusForbidden || got[2] != http.StatusFailedDependency {
+		t.Fatalf("got statuses %v, want 200, 403 and 424 for the skipped delete", got)
+	}
+	if !strings.Contains(string(response.Results[2].Body), `"batch_aborted"`) {
+		t.Errorf("the skipped operation does not say why: %s", response.Results[2].Body)
+	}
+
+	if !tables.ran("ROLLBACK") || tables.ran("COMMIT") {
+		t.Errorf("the batch was not rolled back: %v", tables.statements)
+	}
+	if tables.ran(`DELETE FROM "posts"`) {
+		t.Error("the operation after the failed one was run")
+	}
+}
+
+func TestAtomicBatchCommitsWhenEveryOperationSucceeds(t *testing.T) {
+	tables := blogTables()
+	server := testServer(t, tables.handle)
+
+	response := runBatch(t, server, `{"atomic": true, "operations": [
+		{"method": "GET", "path": "/v1/posts/1"},
+		{"method": "PATCH", "path": "/v1/posts/1", "headers": {"Content-Type": "application/merge-patch+json"}, "body": {"title": "New title"}}
+	]}`)
+	if !response.Committed {
+		t.Errorf("the batch was not committed: %v", statuses(response))
+	}
+	if got := statuses(response); len(got) != 2 || got[0] != http.StatusOK || got[1] != http.StatusOK {
+		t.Fatalf("got statuses %v, want 200 and 200", got)
+	}
+	if response.Results[0].Headers["ETag"] == "" {
+		t.Errorf("the ETag of the post was not passed on: %v", response.Results[0].Headers)
+	}
+	if !tables.ran("COMMIT") || tables.ran("ROLLBACK") {
+		t.Errorf("the batch was not committed: %v", tables.statements)
+	}
+}
+
+func TestBatchRunsEveryOperation(t *testing.T) {
+	tables := blogTables()
+	server := testServer(t, tables.handle)
+
+	response := runBatch(t, server, updateDelete(t, false))
+	if response.Atomic || !response.Committed {
+		t.Errorf("got atomic %v and committed %v, want a batch that is not atomic", response.Atomic, response.Committed)
+	}
+	if got := statuses(response); len(got) != 3 || got[0] != http.StatusOK || got[1] != http.StatusForbidden || got[2] >= http.StatusBadRequest {
+		t.Fatalf("got statuses %v, want the delete to run after the failed update", got)
+	}
+	if !tables.ran(`DELETE FROM "posts"`) {
+		t.Error("the post was not deleted")
+	}
+}
+
+func TestBatchCannotBeNested(t *testing.T) {
+	server := testServer(t, blogTables().handle)
+
+	response := runBatch(t, server, `{"operations": [{"method": "POST", "path": "/v1/batch", "body": {"operations": []}}]}`)
+	if len(response.Results) != 1 {
+		t.Fatalf("got statuses %v, want one result", statuses(response))
+	}
+	if result := response.Results[0]; result.Status != http.StatusBadRequest || !strings.Contains(string(result.Body), `"nested_batch"`) {
+		t.Fatalf("got %d %s, want 400 nested_batch", result.Status, result.Body)
+	}
+}
+
+func TestInvalidBatches(t *testing.T) {
+	server := testServer(t, blogTables().handle)
+
+	for _, test := range []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{name: "path of another host", body: `{"operations": [{"method": "GET", "path": "https://example.com/v1/posts"}]}`, field: `"operations[0].path"`},
+		{name: "unknown method", body: `{"operations": [{"method": "TRACE", "path": "/v1/posts"}]}`, field: `"operations[0].method"`},
+		{name: "idempotency key in an atomic batch", body: `{"atomic": true, "operations": [{"method": "POST", "path": "/v1/posts", "headers": {"idempotency-key": "key-1"}}]}`, field: `"operations[0].headers"`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			w := serve(server, http.MethodPost, "/v1/batch", tokenFor(t, 1), test.body)
+			if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), test.field) {
+				t.Errorf("got %d %s, want 422 pointing at %s", w.Code, w.Body, test.field)
+			}
+		})
+	}
+
+	server.BatchMaxOperations = 1
+	w := serve(server, http.MethodPost, "/v1/batch", tokenFor(t, 1), `{"operations": [{"method": "GET", "path": "/v1/posts"}, {"method": "GET", "path": "/v1/posts"}]}`)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"batch_too_large"`) {
+		t.Errorf("got %d %s, want 422 batch_too_large", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/spam"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/formaterror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
+	"github.com/gorilla/mux"
+)
+
+func (server *Server) CreateComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	comment := models.Comment{}
+	err = validation.Decode(body, &comment)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	commentCreated, err := server.publishComment(r, uid, postid, comment)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/comments/%d", r.Host, commentCreated.ID))
+	responses.JSON(w, http.StatusCreated, dto.NewComment(*commentCreated, server.viewer(r)))
+}
+
+// Function publishComment checks and stores a comment of the user uid on the post postid. It decides whether the comment
+// is published straight away, held for a moderator or treated as spam, and notifies the authors it replies to once it is
+// published. The REST and GraphQL APIs both create comments through it.
+func (server *Server) publishComment(r *http.Request, uid uint32, postid uint64, comment models.Comment) (*models.Comment, error) {
+	// Checks if the post exist
+	post := models.Post{}
+	err := server.db(r).Model(models.Post{}).Where("id = ?", postid).Take(&post).Error
+	if err != nil {
+		return nil, apperror.NotFound("post_not_found", "Post not found")
+	}
+
+	comment.Prepare()
+	comment.PostID = post.ID
+	comment.AuthorID = uid // the author always comes from the token, never from the body
+	err = comment.ValidateComment()
+	if err != nil {
+		return nil, err
+	}
+
+	err = server.moderate(r, &comment)
+	if err != nil {
+		return nil, err
+	}
+
+	commentCreated, err := comment.SaveComment(server.db(r))
+	if err != nil {
+		return nil, formaterror.FormatError(err)
+	}
+
+	if commentCreated.Status == models.CommentApproved {
+		server.afterCommit(r, func() { server.notifyComment(r, commentCreated) })
+	}
+	return commentCreated, nil
+}
+
+// Function moderate scores the content of a comment for spam and sets its status: spam from the spam threshold, pending
+// when the moderation policy holds it for a moderator and approved otherwise. New and edited comments go through it.
+func (server *Server) moderate(r *http.Request, comment *models.Comment) error {
+	comment.SpamScore = 0
+	if server.Spam != nil {
+		comment.SpamScore = server.Spam.Score(comment.Content)
+	}
+	if comment.SpamScore >= spam.Threshold {
+		comment.Status = models.CommentSpam
+		return nil
+	}
+
+	author := models.User{}
+	err := server.db(r).Model(models.User{}).Where("id = ?", comment.AuthorID).Take(&author).Error
+	if err != nil {
+		return apperror.Unauthorized("invalid_token", "Unauthorized")
+	}
+
+	held, err := comment.NeedsModeration(server.db(r))
+	if err != nil {
+		return formaterror.FormatError(err)
+	}
+
+	comment.Status = models.CommentApproved
+	if held && !author.IsModerator() {
+		comment.Status = models.CommentPending
+	}
+	return nil
+}
+
+func (server *Server) GetComments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+
+	// Checks if the post exist
+	err = server.db(r).Model(models.Post{}).Where("id = ?", postid).Take(&models.Post{}).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, apperror.NotFound("post_not_found", "Post not found"))
+		return
+	}
+
+	page, perPage := paginate(r)
+
+	comment := models.Comment{}
+	comments, total, err := comment.FindPostComments(server.db(r), postid, page, perPage)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.List(w, r, http.StatusOK, Page{Data: dto.NewComments(comments, server.viewer(r)), Page: page, PerPage: perPage, Total: total})
+}
+
+func (server *Server) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	commentid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+
+	// Checks if the comment exist
+	comment := models.Comment{}
+	err = server.db(r).Model(models.Comment{}).Where("id = ?", commentid).Take(&comment).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, apperror.NotFound("comment_not_found", "Comment not found"))
+		return
+	}
+
+	// Only the author can edit a comment
+	if uid != comment.AuthorID {
+		responses.ERROR(w, http.StatusForbidden, apperror.Forbidden("not_comment_author", "You can only edit your own comments"))
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	commentUpdate := models.Comment{}
+	err = validation.Decode(body, &commentUpdate)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	commentUpdate.Prepare()
+	commentUpdate.ID = comment.ID
+	commentUpdate.PostID = comment.PostID
+	commentUpdate.AuthorID = comment.AuthorID
+	err = commentUpdate.ValidateComment()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	// The new content is judged again, unless a moderator already rejected the comment or marked it as spam
+	err = server.moderate(r, &commentUpdate)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	if comment.Status == models.CommentRejected || comment.Status == models.CommentSpam {
+		commentUpdate.Status = comment.Status
+	}
+
+	commentUpdated, err := commentUpdate.UpdateComment(server.db(r))
+	if err != nil {
+		formattedError := formaterror.FormatError(err)
+		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		return
+	}
+
+	// The spam filter learnt from the old content, UpdateComment cleared what it learnt
+	if comment.TrainedAs != "" {
+		server.afterCommit(r, func() { server.forgetSpamLesson(r, comment) })
+	}
+	if commentUpdated.Status == models.CommentApproved && comment.Status != models.CommentApproved {
+		server.afterCommit(r, func() { server.notifyComment(r, commentUpdated) })
+	}
+	responses.JSON(w, http.StatusOK, dto.NewComment(*commentUpdated, server.viewer(r)))
+}
+
+func (server *Server) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	commentid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+
+	// Checks if the comment exist
+	comment := models.Comment{}
+	err = server.db(r).Model(models.Comment{}).Where("id = ?", commentid).Take(&comment).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, apperror.NotFound("comment_not_found", "Comment not found"))
+		return
+	}
+
+	// The comment author, the author of the post and moderators are allowed to delete a comment
+	if uid != comment.AuthorID {
+		post := models.Post{}
+		err = server.db(r).Model(models.Post{}).Where("id = ?", comment.PostID).Take(&post).Error
+		if err != nil {
+			responses.ERROR(w, http.StatusNotFound, apperror.NotFound("post_not_found", "Post not found"))
+			return
+		}
+
+		user := models.User{}
+		err = server.db(r).Model(models.User{}).Where("id = ?", uid).Take(&user).Error
+		if err != nil {
+			responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+			return
+		}
+
+		if uid != post.AuthorID && !user.IsModerator() {
+			responses.ERROR(w, http.StatusForbidden, apperror.Forbidden("not_comment_author", "You can only delete comments you wrote or that were left on your posts"))
+			return
+		}
+	}
+
+	_, err = comment.DeleteComment(server.db(r), commentid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Entity", fmt.Sprintf("%d", commentid))
+	responses.NoContent(w)
+}
+
+// Function notifyComment tells the author of the post, and the author of the parent comment for replies, about a published comment
+func (server *Server) notifyComment(r *http.Request, comment *models.Comment) {
+	post := models.Post{}
+	err := server.db(r).Model(models.Post{}).Where("id = ?", comment.PostID).Take(&post).Error
+	if err != nil {
+		return
+	}
+
+	commentid := comment.ID
+	if comment.ParentID != nil {
+		parent := models.Comment{}
+		err = server.db(r).Model(models.Comment{}).Where("id = ?", *comment.ParentID).Take(&parent).Error
+		if err == nil {
+			server.Notifier.Notify(models.NotificationReply, comment.AuthorID, []uint32{parent.AuthorID}, &post.ID, &commentid)
+			if parent.AuthorID == post.AuthorID {
+				return
+			}
+		}
+	}
+	server.Notifier.Notify(models.NotificationComment, comment.AuthorID, []uint32{post.AuthorID}, &post.ID, &commentid)
+}
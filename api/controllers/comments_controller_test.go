@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+)
+
+// Function blogTables holds a user, a post of theirs and a comment of theirs on it
+func blogTables() *fakeTables {
+	now := time.Now()
+	return &fakeTables{rows: map[string]fakeResult{
+		"users": {
+			columns: []string{"id", "user_name", "email", "role", "version", "created_at", "updated_at"},
+			rows:    [][]driver.Value{{int64(1), "ada", "ada@example.com", "user", int64(1), now, now}},
+		},
+		"posts": {
+			columns: []string{"id", "title", "content", "author_id", "version", "created_at", "updated_at"},
+			rows:    [][]driver.Value{{int64(1), "Title", "Content", int64(1), int64(1), now, now}},
+		},
+		"comments": {
+			columns: []string{"id", "content", "post_id", "author_id", "root_id", "depth", "status", "created_at", "updated_at"},
+			rows:    [][]driver.Value{{int64(1), "Comment", int64(1), int64(1), int64(1), int64(0), "approved", now, now}},
+		},
+	}}
+}
+
+func TestReplyToMissingParent(t *testing.T) {
+	tables := blogTables()
+	delete(tables.rows, "comments")
+	server := testServer(t, tables.handle)
+
+	w := serve(server, http.MethodPost, "/v1/posts/1/comments", tokenFor(t, 1), `{"content": "A reply", "parent_id": 9}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("got %d, want 422: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), `"invalid_parent"`) || !strings.Contains(w.Body.String(), `"parent_id"`) {
+		t.Errorf("the problem does not point at parent_id: %s", w.Body)
+	}
+}
+
+// Function commentThreads holds two threads on the post of blogTables: comment 1 with replies 2 and 4 and reply 3 to
+// reply 2, and comment 5 with reply 6
+func commentThreads() *fakeTables {
+	tables := blogTables()
+	now := time.Now()
+	comment := func(id, parent, root, depth int64) []driver.Value {
+		var parentID driver.Value
+		if parent != 0 {
+			parentID = parent
+		}
+		return []driver.Value{id, "Comment", int64(1), int64(1), parentID, root, depth, "approved", now, now}
+	}
+	tables.rows["comments"] = fakeResult{
+		columns: []string{"id", "content", "post_id", "author_id", "parent_id", "root_id", "depth", "status", "created_at", "updated_at"},
+		rows:    [][]driver.Value{comment(1, 0, 1, 0), comment(2, 1, 1, 1), comment(3, 2, 1, 2), comment(4, 1, 1, 1), comment(5, 0, 5, 0), comment(6, 5, 5, 1)},
+	}
+	return tables
+}
+
+// Function deleteTrees runs the recursive delete of deleteCommentTrees on the comments of tables: it drops the comment
+// the statement starts from and, walking parent_id, every reply nested under it
+func deleteTrees(tables *fakeTables) fakeHandler {
+	return func(query string, args []driver.Value) (fakeResult, error) {
+		if !strings.HasPrefix(query, "WITH RECURSIVE tree") {
+			return tables.handle(query, args)
+		}
+		tables.statements = append(tables.statements, query)
+		if !strings.Contains(query, "SELECT id FROM comments WHERE id = $1") ||
+			!strings.Contains(query, "JOIN tree ON comments.parent_id = tree.id") ||
+			!strings.Contains(query, "DELETE FROM comments WHERE id IN (SELECT id FROM tree)") {
+			return fakeResult{}, fmt.Errorf("unexpected statement %q", query)
+		}
+
+		comments := tables.rows["comments"]
+		tree := map[driver.Value]bool{args[0]: true}
+		for grown := true; grown; {
+			grown = false
+			for _, row := range comments.rows {
+				if parent := row[4]; parent != nil && tree[parent] && !tree[row[0]] {
+					tree[row[0]] = true
+					grown = true
+				}
+			}
+		}
+
+		kept := [][]driver.Value{}
+		for _, row := range comments.rows {
+			if !tree[row[0]] {
+				kept = append(kept, row)
+			}
+		}
+		affected := int64(len(comments.rows) - len(kept))
+		comments.rows = kept
+		tables.rows["comments"] = comments
+		return fakeResult{affected: affected}, nil
+	}
+}
+
+func TestDeleteCommentDeletesItsThread(t *testing.T) {
+	tables := commentThreads()
+	server := testServer(t, deleteTrees(tables))
+
+	w := serve(server, http.MethodDelete, "/v1/comments/1", tokenFor(t, 1), "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("got %d, want 204: %s", w.Code, w.Body)
+	}
+
+	left := []driver.Value{}
+	for _, row := range tables.rows["comments"].rows {
+		left = append(left, row[0])
+	}
+	if !reflect.DeepEqual(left, []driver.Value{int64(5), int64(6)}) {
+		t.Errorf("comments %v are left, want the other thread [5 6]", left)
+	}
+}
+
+func TestEditedCommentIsModeratedAgain(t *testing.T) {
+	policy := models.ModerationPolicy
+	t.Cleanup(func() { models.ModerationPolicy = policy })
+
+	for _, test := range []struct {
+		name      string
+		status    string
+		trainedAs string
+		policy    string
+		score     float64
+		want      string
+		lessons   []string
+	}{
+		{name: "edited into spam", status: "approved", policy: models.ModerateNone, score: 0.95, want: "spam"},
+		{name: "held by the policy", status: "approved", policy: models.ModerateAll, want: "pending"},
+		{name: "still fine", status: "approved", policy: models.ModerateNone, want: "approved"},
+		{name: "pending and now fine", status: "pending", policy: models.ModerateNone, want: "approved"},
+		{name: "rejected stays rejected", status: "rejected", policy: models.ModerateNone, want: "rejected"},
+		{name: "spam stays spam", status: "spam", policy: models.ModerateNone, want: "spam"},
+		{name: "learnt from the old content", status: "approved", trainedAs: "approved", policy: models.ModerateNone, want: "approved", lessons: []string{"forget ham"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			models.ModerationPolicy = test.policy
+			tables := blogTables()
+			comments := tables.rows["comments"]
+			comments.rows[0][6] = test.status
+			comments.columns = append(comments.columns, "trained_as")
+			comments.rows[0] = append(comments.rows[0], test.trainedAs)
+			tables.rows["comments"] = comments
+
+			var update map[string]driver.Value
+			handle := func(query string, args []driver.Value) (fakeResult, error) {
+				if strings.HasPrefix(query, `UPDATE "comments"`) {
+					update = map[string]driver.Value{}
+					set := strings.Split(strings.TrimSpace(query[strings.Index(query, "SET")+3:strings.Index(query, "WHERE")]), ",")
+					for i, assignment := range set {
+						column, _, _ := strings.Cut(strings.TrimSpace(assignment), "=")
+						update[strings.Trim(strings.TrimSpace(column), `"`)] = args[i]
+					}
+				}
+				return tables.handle(query, args)
+			}
+
+			trainer := &recordingTrainer{score: test.score}
+			server := testServer(t, handle, func(server *Server) { server.Spam = trainer })
+			w := serve(server, http.MethodPut, "/v1/comments/1", tokenFor(t, 1), `{"content": "An edit"}`)
+			if w.Code != http.StatusOK {
+				t.Fatalf("got %d, want 200: %s", w.Code, w.Body)
+			}
+			if update["status"] != test.want || update["trained_as"] != "" || update["spam_score"] != test.score {
+				t.Errorf("the edit stored %v, want status %q, spam score %v and nothing learnt", update, test.want, test.score)
+			}
+			if !reflect.DeepEqual(trainer.lessons, test.lessons) {
+				t.Errorf("the spam filter was taught %q, want %q", trainer.lessons, test.lessons)
+			}
+		})
+	}
+}
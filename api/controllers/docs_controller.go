@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"embed"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"path"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/graph"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/health"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/openapi"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/patch"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/gorilla/mux"
+)
+
+// page and cursorPage mirror Page and CursorPage with a typed data field, they only exist to document list responses
+type page[T any] struct {
+	Data    []T   `json:"data"`
+	Page    int   `json:"page"`
+	PerPage int   `json:"per_page"`
+	Total   int64 `json:"total"`
+}
+
+type cursorPage[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor"`
+}
+
+type commentRequest struct {
+	Content  string  `json:"content"`
+	ParentID *uint64 `json:"parent_id"`
+}
+
+var pageQuery = []string{"page", "per_page"}
+
+// selectQuery trims and expands the resources of a read route, see the fieldset package
+var selectQuery = []string{"fields", "expand"}
+
+// Function patchRequests documents a PATCH body, fields is the object a merge patch changes and JSON patch paths point into
+func patchRequests(fields interface{}) map[string]interface{} {
+	return map[string]interface{}{patch.MergePatch: fields, patch.JSONPatch: []patch.Operation{}}
+}
+
+// routeDocs documents every route registered in initializeRoutes, versioned routes are keyed without their prefix.
+// A route missing here is reported when the server starts and fails the tests.
+var routeDocs = map[string]openapi.Operation{
+	"GET /":       {Summary: "Welcome message", Tag: "home", Response: ""},
+	"POST /login": {Summary: "Exchange credentials for a token", Tag: "auth", Request: dto.Credentials{}, Response: ""},
+
+	"POST /users":               {Summary: "Register a user", Tag: "users", Request: dto.UserRequest{}, Response: dto.User{}, Status: http.StatusCreated, Idempotent: true},
+	"GET /users":                {Summary: "List users", Tag: "users", Query: selectQuery, Response: []dto.User{}},
+	"GET /users/{id}":           {Summary: "Get a user", Tag: "users", Query: selectQuery, Response: dto.User{}},
+	"PUT /users/{id}":           {Summary: "Update your profile", Tag: "users", Auth: true, Request: dto.UserRequest{}, Response: dto.User{}},
+	"PATCH /users/{id}":         {Summary: "Change some fields of your profile", Tag: "users", Auth: true, Requests: patchRequests(userPatch{}), Response: dto.User{}},
+	"PUT /users/{id}/password":  {Summary: "Change your password", Tag: "users", Auth: true, Request: passwordChange{}, Status: http.StatusNoContent},
+	"DELETE /users/{id}":        {Summary: "Delete your account", Tag: "users", Auth: true, Status: http.StatusNoContent},
+	"PUT /users/{id}/follow":    {Summary: "Follow a user", Tag: "follows", Auth: true, Response: dto.User{}},
+	"DELETE /users/{id}/follow": {Summary: "Unfollow a user", Tag: "follows", Auth: true, Response: dto.User{}},
+	"GET /users/{id}/followers": {Summary: "List the followers of a user", Tag: "follows", Query: pageQuery, Response: page[dto.User]{}},
+	"GET /users/{id}/following": {Summary: "List the users a user follows", Tag: "follows", Query: pageQuery, Response: page[dto.User]{}},
+	"GET /users/{id}/bookmarks": {Summary: "List your bookmarked posts", Tag: "bookmarks", Auth: true, Query: pageQuery, Response: page[dto.Post]{}},
+
+	"POST /posts":        {Summary: "Create a post", Tag: "posts", Auth: true, Request: dto.PostRequest{}, Response: dto.Post{}, Status: http.StatusCreated, Idempotent: true},
+	"GET /posts":         {Summary: "List posts", Tag: "posts", Query: selectQuery, Response: []dto.Post{}},
+	"GET /posts/{id}":    {Summary: "Get a post", Tag: "posts", Query: selectQuery, Response: dto.Post{}},
+	"PUT /posts/{id}":    {Summary: "Update your post", Tag: "posts", Auth: true, Request: dto.PostRequest{}, Response: dto.Post{}},
+	"PATCH /posts/{id}":  {Summary: "Change some fields of your post", Tag: "posts", Auth: true, Requests: patchRequests(postPatch{}), Response: dto.Post{}},
+	"DELETE /posts/{id}": {Summary: "Delete your post", Tag: "posts", Auth: true, Status: http.StatusNoContent},
+
+	"GET /feed": {Summary: "Recent posts of the authors you follow", Tag: "follows", Auth: true, Query: []string{"cursor", "per_page"}, Response: cursorPage[dto.Post]{}},
+
+	"GET /notifications":             {Summary: "List your notifications", Tag: "notifications", Auth: true, Query: []string{"page", "per_page", "unread"}, Response: page[dto.Notification]{}},
+	"PUT /notifications/read":        {Summary: "Mark all your notifications read", Tag: "notifications", Auth: true, Status: http.StatusNoContent},
+	"GET /notifications/stream":      {Summary: "Receive your notifications live as Server-Sent Events", Tag: "notifications", Auth: true, Query: []string{"token"}, Response: "", ContentType: "text/event-stream"},
+	"GET /notifications/preferences": {Summary: "Get your email preferences", Tag: "notifications", Auth: true, Response: []models.NotificationPreference{}},
+	"PUT /notifications/preferences": {Summary: "Update your email preferences", Tag: "notifications", Auth: true, Request: []models.NotificationPreference{}, Response: []models.NotificationPreference{}},
+	"PUT /notifications/{id}/read":   {Summary: "Mark a notification read", Tag: "notifications", Auth: true, Status: http.StatusNoContent},
+
+	"PUT /posts/{id}/reactions/{reaction}":    {Summary: "React to a post", Tag: "reactions", Auth: true, Response: dto.Post{}},
+	"DELETE /posts/{id}/reactions/{reaction}": {Summary: "Remove your reaction from a post", Tag: "reactions", Auth: true, Response: dto.Post{}},
+	"PUT /posts/{id}/bookmark":                {Summary: "Bookmark a post", Tag: "bookmarks", Auth: true, Response: models.Bookmark{}},
+	"DELETE /posts/{id}/bookmark":             {Summary: "Remove a bookmark", Tag: "bookmarks", Auth: true, Status: http.StatusNoContent},
+
+	"POST /posts/{id}/comments": {Summary: "Comment on a post", Tag: "comments", Auth: true, Request: commentRequest{}, Response: dto.Comment{}, Status: http.StatusCreated, Idempotent: true},
+	"GET /posts/{id}/comments":  {Summary: "List the comment threads of a post", Tag: "comments", Query: pageQuery, Response: page[dto.Comment]{}},
+	"PUT /comments/{id}":        {Summary: "Edit your comment", Tag: "comments", Auth: true, Request: commentRequest{}, Response: dto.Comment{}},
+	"DELETE /comments/{id}":     {Summary: "Delete a comment", Tag: "comments", Auth: true, Status: http.StatusNoContent},
+
+	"GET /admin/comments":              {Summary: "List comments awaiting moderation", Tag: "moderation", Auth: true, Query: []string{"status", "page", "per_page"}, Response: page[dto.Comment]{}},
+	"PUT /admin/comments/{id}/approve": {Summary: "Approve a comment", Tag: "moderation", Auth: true, Response: dto.Comment{}},
+	"PUT /admin/comments/{id}/reject":  {Summary: "Reject a comment", Tag: "moderation", Auth: true, Response: dto.Comment{}},
+	"PUT /admin/comments/{id}/spam":    {Summary: "Mark a comment as spam", Tag: "moderation", Auth: true, Response: dto.Comment{}},
+
+	"POST /batch": {Summary: "Run several operations in one request, atomically on a single transaction when atomic is set", Tag: "batch", Request: dto.BatchRequest{Operations: []dto.BatchOperation{{Method: "PATCH", Path: "/v1/posts/1", Headers: map[string]string{"Content-Type": patch.MergePatch, "If-Match": `"v3-5f1c0e2ab94d7c61"`}, Body: json.RawMessage(`{"title":"New title"}`)}}}, Response: dto.BatchResponse{}, Idempotent: true},
+
+	"GET /openapi.json": {Summary: "This OpenAPI document", Tag: "docs", Response: map[string]interface{}{}},
+	"GET /docs":         {Summary: "Interactive API documentation", Tag: "docs", Response: "", ContentType: "text/html"},
+	"GET /docs/{asset}": {Summary: "The Swagger UI files the documentation page loads", Tag: "docs", Response: ""},
+	"GET /healthz":      {Summary: "Liveness probe", Tag: "operations", Response: Liveness{}},
+	"GET /readyz":       {Summary: "Readiness probe, answers 503 with the failing checks while not ready", Tag: "operations", Response: health.Report{}},
+	"GET /metrics":      {Summary: "Prometheus metrics", Tag: "operations", Response: "", ContentType: "text/plain"},
+
+	"GET /graphql":            {Summary: "Run a GraphQL query given in the query, operationName and variables parameters", Tag: "graphql", Query: []string{"query", "operationName", "variables"}, Response: map[string]interface{}{}},
+	"POST /graphql":           {Summary: "Run a GraphQL query or mutation, mutations need a bearer token", Tag: "graphql", Request: graph.Request{}, Response: map[string]interface{}{}},
+	"GET /graphql/playground": {Summary: "GraphiQL, only served in development", Tag: "graphql", Response: "", ContentType: "text/html"},
+}
+
+// Function buildSpec documents the routes registered on the router and logs the ones missing from routeDocs
+func (server *Server) buildSpec() {
+	spec, missing := openapi.Build(server.Router, "Blog API", "1.0.0", routeDocs, server.legacy)
+	for _, route := range missing {
+		slog.Warn("route is missing from the OpenAPI document", "route", route)
+	}
+	server.spec = spec
+}
+
+func (server *Server) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, http.StatusOK, server.spec)
+}
+
+func (server *Server) Docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(docsPage))
+}
+
+// swaggerUI holds the Swagger UI files the documentation page loads, so it works without reaching a CDN
+//
+//go:embed swaggerui/swagger-ui.css swaggerui/swagger-ui-bundle.js
+var swaggerUI embed.FS
+
+// Function DocsAsset serves a file of Swagger UI, they are cached for a day since they only change with the binary
+func (server *Server) DocsAsset(w http.ResponseWriter, r *http.Request) {
+	asset := mux.Vars(r)["asset"]
+	content, err := swaggerUI.ReadFile("swaggerui/" + asset)
+	if err != nil {
+		server.NotFound(w, r)
+		return
+	}
+	switch path.Ext(asset) {
+	case ".css":
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	case ".js":
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	}
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title>Blog API</title>
+	<link rel="stylesheet" href="/docs/swagger-ui.css">
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script src="/docs/swagger-ui-bundle.js"></script>
+	<script>
+		window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+	</script>
+</body>
+</html>
+`
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/openapi"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/gorilla/mux"
+)
+
+// passwordHash is the password column the fake database returns, responses must never show it
+const passwordHash = "$2a$10$abcdefghijklmnopqrstuvhashedpasswordhashedpasswordhash"
+
+// Function usersWithPasswords answers every query on users with a user whose row holds a password hash, whatever
+// columns the query asked for
+func usersWithPasswords(query string, args []driver.Value) (fakeResult, error) {
+	if !strings.Contains(query, `FROM "users"`) {
+		return fakeResult{}, nil
+	}
+	now := time.Now()
+	return fakeResult{
+		columns: userColumns,
+		rows:    [][]driver.Value{{int64(1), "ada", "ada@example.com", passwordHash, "admin", int64(1), now, now}},
+	}, nil
+}
+
+// Function hasKey reports whether a JSON document has an object with the given key at any depth
+func hasKey(value interface{}, key string) bool {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for name, child := range value {
+			if strings.EqualFold(name, key) || hasKey(child, key) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range value {
+			if hasKey(child, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestDocumentedResponsesHaveNoPassword(t *testing.T) {
+	for route, operation := range routeDocs {
+		if slices.Contains(openapi.Properties(operation.Response), "password") {
+			t.Errorf("the response of %s has a password field", route)
+		}
+	}
+}
+
+func TestUserResponsesHaveNoPassword(t *testing.T) {
+	server := testServer(t, usersWithPasswords)
+	for _, token := range []string{"", tokenFor(t, 1), tokenFor(t, 2)} {
+		for _, path := range []string{"/v1/users", "/v1/users/1", "/users/1"} {
+			w := serve(server, http.MethodGet, path, token, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %s: got %d, want 200: %s", path, w.Code, w.Body)
+			}
+			if strings.Contains(w.Body.String(), passwordHash) {
+				t.Errorf("GET %s shows the password hash: %s", path, w.Body)
+			}
+			body := map[string]interface{}{}
+			_ = json.Unmarshal(w.Body.Bytes(), &body)
+			if hasKey(body, "password") {
+				t.Errorf("GET %s has a password field: %s", path, w.Body)
+			}
+		}
+	}
+}
+
+func TestGraphQLUsersHaveNoPassword(t *testing.T) {
+	server := testServer(t, usersWithPasswords)
+	w := serve(server, http.MethodPost, "/graphql", tokenFor(t, 1), `{"query": "{ user(id: 1) { id userName email } }"}`)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), passwordHash) || strings.Contains(w.Body.String(), "password") {
+		t.Fatalf("GraphQL user: %d %s", w.Code, w.Body)
+	}
+
+	w = serve(server, http.MethodPost, "/graphql", tokenFor(t, 1), `{"query": "{ user(id: 1) { id password } }"}`)
+	if !strings.Contains(w.Body.String(), `Cannot query field \"password\"`) {
+		t.Fatalf("GraphQL should have no password field: %s", w.Body)
+	}
+}
+
+func TestRoutesAreDocumented(t *testing.T) {
+	server := testServer(t, usersWithPasswords, func(server *Server) { server.GraphQLPlayground = true })
+	_, missing := openapi.Build(server.Router, "Blog API", "1.0.0", routeDocs, server.legacy)
+	for _, route := range missing {
+		t.Errorf("%s is routed but missing from routeDocs", route)
+	}
+
+	routed := map[string]bool{}
+	server.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			routed[openapi.Key(method, strings.TrimPrefix(template, "/v1"))] = true
+		}
+		return nil
+	})
+	for route := range routeDocs {
+		if !routed[route] {
+			t.Errorf("%s is documented but not routed", route)
+		}
+	}
+}
+
+func TestDocsPageIsSelfContained(t *testing.T) {
+	server := testServer(t, usersWithPasswords)
+	w := serve(server, http.MethodGet, "/docs", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /docs: got %d, want 200", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "https://") {
+		t.Errorf("the docs page loads files from another host: %s", w.Body)
+	}
+
+	for asset, contentType := range map[string]string{"/docs/swagger-ui.css": "text/css", "/docs/swagger-ui-bundle.js": "text/javascript"} {
+		if !strings.Contains(w.Body.String(), `"`+asset+`"`) {
+			t.Errorf("the docs page does not load %s", asset)
+		}
+		w := serve(server, http.MethodGet, asset, "", "")
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), contentType) || w.Body.Len() == 0 {
+			t.Errorf("GET %s: got %d %q with %d bytes", asset, w.Code, w.Header().Get("Content-Type"), w.Body.Len())
+		}
+	}
+
+	w = serve(server, http.MethodGet, "/docs/missing.js", "", "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /docs/missing.js: got %d, want 404", w.Code)
+	}
+}
+
+func TestBatchExampleUsesValidETags(t *testing.T) {
+	request, ok := routeDocs["POST /batch"].Request.(dto.BatchRequest)
+	if !ok {
+		t.Fatal("POST /batch is not documented with a batch request")
+	}
+	for _, operation := range request.Operations {
+		value, ok := header(operation.Headers, "If-Match")
+		if !ok {
+			continue
+		}
+		r := httptest.NewRequest(operation.Method, operation.Path, nil)
+		r.Header.Set("If-Match", value)
+		if _, err := responses.IfMatch(r); err != nil {
+			t.Errorf("the example If-Match %s is refused: %v", value, err)
+		}
+	}
+}
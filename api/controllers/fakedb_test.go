@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeResult is what a fake database answers to a statement: the rows of a query or the rows an exec affected
+type fakeResult struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+}
+
+// fakeHandler answers the statements sent to a fake database, tests match them on their SQL
+type fakeHandler func(query string, args []driver.Value) (fakeResult, error)
+
+// Function fakeDB opens gorm on a database whose statements are answered by handle, so handlers run without Postgres.
+// Statements are serialized, handlers can keep their state in plain maps.
+func fakeDB(t *testing.T, handle fakeHandler) *gorm.DB {
+	t.Helper()
+	sqlDB := sql.OpenDB(&fakeConnector{handle: handle})
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(models.Postgres(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("cannot open the fake database: %v", err)
+	}
+	return db
+}
+
+// Function columnsOf returns the columns an INSERT statement writes, in the order of its arguments
+func columnsOf(query string) []string {
+	start := strings.Index(query, "(")
+	end := strings.Index(query, ")")
+	if start < 0 || end < start {
+		return nil
+	}
+	columns := strings.Split(query[start+1:end], ",")
+	for i := range columns {
+		columns[i] = strings.Trim(strings.TrimSpace(columns[i]), `"`)
+	}
+	return columns
+}
+
+type fakeConnector struct {
+	mu     sync.Mutex
+	handle fakeHandler
+}
+
+func (connector *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{connector: connector}, nil
+}
+
+func (connector *fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, driver.ErrSkip
+}
+
+type fakeConn struct {
+	connector *fakeConnector
+}
+
+func (conn *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: conn, query: query}, nil
+}
+
+func (conn *fakeConn) Close() error {
+	return nil
+}
+
+func (conn *fakeConn) Begin() (driver.Tx, error) {
+	_, err := conn.run("BEGIN", nil)
+	return fakeTx{conn: conn}, err
+}
+
+func (conn *fakeConn) run(query string, args []driver.Value) (fakeResult, error) {
+	conn.connector.mu.Lock()
+	defer conn.connector.mu.Unlock()
+	return conn.connector.handle(query, args)
+}
+
+// fakeTx sends the end of a transaction to the handler as COMMIT or ROLLBACK, so tests can tell which one happened
+type fakeTx struct {
+	conn *fakeConn
+}
+
+func (tx fakeTx) Commit() error {
+	_, err := tx.conn.run("COMMIT", nil)
+	return err
+}
+
+func (tx fakeTx) Rollback() error {
+	_, err := tx.conn.run("ROLLBACK", nil)
+	return err
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (stmt *fakeStmt) Close() error {
+	return nil
+}
+
+func (stmt *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (stmt *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	result, err := stmt.conn.run(stmt.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(result.affected), nil
+}
+
+func (stmt *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	result, err := stmt.conn.run(stmt.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{result: result}, nil
+}
+
+type fakeRows struct {
+	result fakeResult
+	next   int
+}
+
+func (rows *fakeRows) Columns() []string {
+	return rows.result.columns
+}
+
+func (rows *fakeRows) Close() error {
+	return nil
+}
+
+func (rows *fakeRows) Next(dest []driver.Value) error {
+	if rows.next >= len(rows.result.rows) {
+		return io.EOF
+	}
+	copy(dest, rows.result.rows[rows.next])
+	rows.next++
+	return nil
+}
+
+// fakeTables answers the queries on a table with its rows whatever their conditions, counts with the number of rows,
+// inserts with the row they wrote under the next id and other statements as if they changed a row. It records every
+// statement so tests can check what was run.
+type fakeTables struct {
+	rows       map[string]fakeResult
+	statements []string
+}
+
+func (tables *fakeTables) handle(query string, args []driver.Value) (fakeResult, error) {
+	tables.statements = append(tables.statements, query)
+	if strings.HasPrefix(query, "INSERT INTO") && strings.Contains(query, "RETURNING") {
+		return tables.inserted(query, args), nil
+	}
+	if !strings.HasPrefix(query, "SELECT") {
+		return fakeResult{affected: 1}, nil
+	}
+	for table, result := range tables.rows {
+		if !strings.Contains(query, `FROM "`+table+`"`) {
+			continue
+		}
+		if strings.HasPrefix(query, "SELECT count(") {
+			return fakeResult{columns: []string{"count"}, rows: [][]driver.Value{{int64(len(result.rows))}}}, nil
+		}
+		return result, nil
+	}
+	return fakeResult{}, nil
+}
+
+// Function inserted returns the columns an INSERT statement asks back, the row it wrote with the next id of its table
+func (tables *fakeTables) inserted(query string, args []driver.Value) fakeResult {
+	table := strings.Trim(strings.Fields(strings.TrimPrefix(query, "INSERT INTO "))[0], `"`)
+	row := map[string]driver.Value{"id": int64(len(tables.rows[table].rows) + 1)}
+	for i, column := range columnsOf(query) {
+		if i < len(args) {
+			row[column] = args[i]
+		}
+	}
+
+	_, returning, _ := strings.Cut(query, "RETURNING ")
+	result := fakeResult{rows: [][]driver.Value{{}}, affected: 1}
+	for _, column := range strings.Split(returning, ",") {
+		column = strings.Trim(strings.TrimSpace(column), `"`)
+		result.columns = append(result.columns, column)
+		result.rows[0] = append(result.rows[0], row[column])
+	}
+	return result
+}
+
+// Function ran reports whether a statement containing the given SQL was run
+func (tables *fakeTables) ran(sql string) bool {
+	for _, statement := range tables.statements {
+		if strings.Contains(statement, sql) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/formaterror"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+func (server *Server) FollowUser(w http.ResponseWriter, r *http.Request) {
+	server.follow(w, r, true)
+}
+
+func (server *Server) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	server.follow(w, r, false)
+}
+
+// Function follow makes the authenticated user follow or unfollow the user in the path and responds with the updated profile
+func (server *Server) follow(w http.ResponseWriter, r *http.Request, add bool) {
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+
+	tokenID, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+
+	// Checks if the user exist
+	user := models.User{}
+	_, err = user.FindUserByID(server.db(r), uint32(uid))
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, apperror.NotFound("user_not_found", "User not found"))
+		return
+	}
+
+	follow := models.Follow{FollowerID: tokenID, FolloweeID: uint32(uid)}
+	if add {
+		var existing int64
+		err = server.db(r).Model(models.Follow{}).Where("follower_id = ? AND followee_id = ?", tokenID, uid).Count(&existing).Error
+		if err == nil {
+			_, err = follow.SaveFollow(server.db(r))
+		}
+		if err == nil && existing == 0 {
+			server.afterCommit(r, func() {
+				server.Notifier.Notify(models.NotificationFollow, tokenID, []uint32{uint32(uid)}, nil, nil)
+			})
+		}
+	} else {
+		_, err = follow.DeleteFollow(server.db(r))
+	}
+	if err != nil {
+		formattedError := formaterror.FormatError(err)
+		responses.ERROR(w, http.StatusUnprocessableEntity, formattedError)
+		return
+	}
+
+	userFollowed, err := user.FindUserByID(server.db(r), uint32(uid))
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, dto.NewUser(*userFollowed, server.viewer(r)))
+}
+
+func (server *Server) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	server.listFollows(w, r, models.FindFollowers)
+}
+
+func (server *Server) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	server.listFollows(w, r, models.FindFollowing)
+}
+
+func (server *Server) listFollows(w http.ResponseWriter, r *http.Request, find func(db *gorm.DB, uid uint32, page, perPage int) ([]models.User, int64, error)) {
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+
+	page, perPage := paginate(r)
+
+	users, total, err := find(server.db(r), uint32(uid), page, perPage)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.List(w, r, http.StatusOK, Page{Data: dto.NewUsers(users, server.viewer(r)), Page: page, PerPage: perPage, Total: total})
+}
+
+func (server *Server) GetFeed(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+
+	var cursor *models.FeedCursor
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		cursor, err = models.DecodeFeedCursor(value)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	_, limit := paginate(r)
+
+	posts, next, err := models.FindFeed(server.db(r), uid, cursor, limit)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	err = models.LoadReactions(server.db(r), posts, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	feed := CursorPage{Data: dto.NewPosts(posts, server.viewer(r))}
+	if next != nil {
+		feed.NextCursor = next.Encode()
+	}
+	responses.List(w, r, http.StatusOK, feed)
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/graph"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/graphql-go/graphql"
+)
+
+// graphqlRequest is what the resolvers of a GraphQL request share: the HTTP request, who the response is written for
+// and the loaders that batch the queries of the request
+type graphqlRequest struct {
+	r       *http.Request
+	viewer  dto.Viewer
+	loaders graphqlLoaders
+}
+
+type graphqlKey struct{}
+
+// Function requestOf returns the GraphQL request a resolver runs for
+func requestOf(p graphql.ResolveParams) *graphqlRequest {
+	return p.Context.Value(graphqlKey{}).(*graphqlRequest)
+}
+
+// Function uid returns the user behind the token of the request, mutations need one like the private REST routes do
+func (request *graphqlRequest) uid() (uint32, error) {
+	uid, err := auth.ExtractTokenID(request.r)
+	if err != nil {
+		return 0, apperror.Unauthorized("invalid_token", "Unauthorized")
+	}
+	return uid, nil
+}
+
+// Function GraphQL runs GraphQL queries sent with GET or POST and mutations sent with POST. Results are answered with
+// 200 even when they hold errors, as GraphQL clients expect, only a request that cannot be read gets a problem.
+func (server *Server) GraphQL(w http.ResponseWriter, r *http.Request) {
+	request, err := graph.ReadRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limits := server.GraphQLLimits
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = graph.DefaultLimits.MaxDepth
+	}
+	if limits.MaxComplexity <= 0 {
+		limits.MaxComplexity = graph.DefaultLimits.MaxComplexity
+	}
+
+	ctx := context.WithValue(r.Context(), graphqlKey{}, &graphqlRequest{r: r, viewer: server.viewer(r), loaders: server.graphqlLoaders(r)})
+	result := graph.Execute(ctx, server.graphql, request, limits, r.Method == http.MethodGet)
+	responses.JSON(w, http.StatusOK, result)
+}
+
+// graphqlLoaders batch the queries of a GraphQL request, one loader per kind of load
+type graphqlLoaders struct {
+	users         *graph.Loader[uint32, models.User]
+	followCounts  *graph.Loader[uint32, models.User]
+	posts         *graph.Loader[uint64, models.Post]
+	commentCounts *graph.Loader[uint64, int64]
+	threads       *graph.Loader[graph.PageKey[uint64], []*models.Comment]
+	threadCounts  *graph.Loader[uint64, int64]
+	authorPosts   *graph.Loader[graph.PageKey[uint32], []models.Post]
+	postCounts    *graph.Loader[uint32, int64]
+}
+
+// Function graphqlLoaders returns the loaders of a request, pages are fetched with one extra node to tell whether a
+// next page exists
+func (server *Server) graphqlLoaders(r *http.Request) graphqlLoaders {
+	db := server.db(r)
+	return graphqlLoaders{
+		users: graph.NewLoader(func(_ context.Context, uids []uint32) (map[uint32]models.User, error) {
+			users, err := models.FindUsersByIDs(db, uids)
+			byID := make(map[uint32]models.User, len(users))
+			for _, user := range users {
+				byID[user.ID] = user
+			}
+			return byID, err
+		}),
+		followCounts: graph.NewLoader(func(_ context.Context, uids []uint32) (map[uint32]models.User, error) {
+			users := make([]models.User, len(uids))
+			for i, uid := range uids {
+				users[i].ID = uid
+			}
+			err := models.LoadFollowCounts(db, users)
+			byID := make(map[uint32]models.User, len(users))
+			for _, user := range users {
+				byID[user.ID] = user
+			}
+			return byID, err
+		}),
+		posts: graph.NewLoader(func(_ context.Context, postids []uint64) (map[uint64]models.Post, error) {
+			posts, err := models.FindPostsByIDs(db, postids)
+			byID := make(map[uint64]models.Post, len(posts))
+			for _, post := range posts {
+				byID[post.ID] = post
+			}
+			return byID, err
+		}),
+		commentCounts: graph.NewLoader(func(_ context.Context, postids []uint64) (map[uint64]int64, error) {
+			return models.CountPostComments(db, postids)
+		}),
+		threads: graph.NewLoader(graph.ByPage(func(_ context.Context, postids []uint64, page graph.Page) (map[uint64][]*models.Comment, error) {
+			return models.FindPostsThreads(db, postids, page.After, page.First+1)
+		})),
+		threadCounts: graph.NewLoader(func(_ context.Context, postids []uint64) (map[uint64]int64, error) {
+			return models.CountPostsThreads(db, postids)
+		}),
+		authorPosts: graph.NewLoader(graph.ByPage(func(_ context.Context, uids []uint32, page graph.Page) (map[uint32][]models.Post, error) {
+			return models.FindAuthorsPosts(db, uids, page.After, page.First+1)
+		})),
+		postCounts: graph.NewLoader(func(_ context.Context, uids []uint32) (map[uint32]int64, error) {
+			return models.CountAuthorsPosts(db, uids)
+		}),
+	}
+}
+
+// Function count adapts a loaded count to the totalCount of a connection
+func count[K comparable](ctx context.Context, loader *graph.Loader[K, int64], key K) func() func() (interface{}, error) {
+	return func() func() (interface{}, error) {
+		thunk := loader.Load(ctx, key)
+		return func() (interface{}, error) {
+			return thunk()
+		}
+	}
+}
+
+// Function parseID reads an ID argument, IDs are the numeric ids of the REST API sent as strings
+func parseID(value interface{}, bitSize int) (uint64, error) {
+	id, err := strconv.ParseUint(value.(string), 10, bitSize)
+	if err != nil {
+		return 0, apperror.BadRequest("invalid_id", "The id is not valid")
+	}
+	return id, nil
+}
+
+// Function GraphiQL serves GraphiQL to try queries in the browser, it is only routed in development
+func (server *Server) GraphiQL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(playgroundPage))
+}
+
+const playgroundPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title>Blog API GraphQL</title>
+	<link rel="stylesheet" href="https://unpkg.com/graphiql@3/graphiql.min.css">
+</head>
+<body style="margin: 0">
+	<div id="graphiql" style="height: 100vh"></div>
+	<script src="https://unpkg.com/react@18/umd/react.production.min.js"></script>
+	<script src="https://unpkg.com/react-dom@18/umd/react-dom.production.min.js"></script>
+	<script src="https://unpkg.com/graphiql@3/graphiql.min.js"></script>
+	<script>
+		const fetcher = GraphiQL.createFetcher({ url: "/graphql" });
+		ReactDOM.createRoot(document.getElementById("graphiql")).render(React.createElement(GraphiQL, { fetcher }));
+	</script>
+</body>
+</html>
+`
@@ -0,0 +1,442 @@
+package controllers
+
+import (
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/graph"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/graphql-go/graphql"
+)
+
+// Function graphqlSchema builds the GraphQL schema. Its objects are the dto types the REST API responds with, so a user
+// shows the same fields to the same viewer whichever API is asked, and its mutations go through the code the REST
+// handlers use to validate and authorize changes.
+func (server *Server) graphqlSchema() (graphql.Schema, error) {
+	var userType, postType, commentType *graphql.Object
+	var userConnection, postConnection, commentConnection *graphql.Object
+
+	userType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":             &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"userName":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"email":          &graphql.Field{Type: graphql.String, Description: "Only shown to the user and to administrators", Resolve: private(func(user dto.User) string { return user.Email })},
+				"role":           &graphql.Field{Type: graphql.String, Description: "Only shown to the user and to administrators", Resolve: private(func(user dto.User) string { return user.Role })},
+				"followersCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: followCount(func(user models.User) int64 { return user.FollowersCount })},
+				"followingCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: followCount(func(user models.User) int64 { return user.FollowingCount })},
+				"posts": &graphql.Field{
+					Type: graphql.NewNonNull(postConnection),
+					Args: graph.ConnectionArgs,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						request, user := requestOf(p), p.Source.(dto.User)
+						page, err := graph.PageOf(p.Args)
+						if err != nil {
+							return nil, graph.Error(err)
+						}
+						thunk := request.loaders.authorPosts.Load(p.Context, graph.PageKey[uint32]{Parent: user.ID, Page: page})
+						return func() (interface{}, error) {
+							posts, err := thunk()
+							if err != nil {
+								return nil, err
+							}
+							return graph.NewConnection(dto.NewPosts(posts, request.viewer), page, postID, count(p.Context, request.loaders.postCounts, user.ID)), nil
+						}, nil
+					},
+				},
+				"version":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"updatedAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			}
+		}),
+	})
+
+	postType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Post",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":      &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"title":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"content": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"author": &graphql.Field{
+					Type: graphql.NewNonNull(userType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						post := p.Source.(dto.Post)
+						if post.Author.ID != 0 {
+							return post.Author, nil
+						}
+						return loadUser(p, post.AuthorID), nil
+					},
+				},
+				"commentCount": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.Int),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return count(p.Context, requestOf(p).loaders.commentCounts, p.Source.(dto.Post).ID)(), nil
+					},
+				},
+				"comments": &graphql.Field{
+					Type:        graphql.NewNonNull(commentConnection),
+					Description: "The comment threads of the post, replies are nested under the comments they answer",
+					Args:        graph.ConnectionArgs,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						request, post := requestOf(p), p.Source.(dto.Post)
+						page, err := graph.PageOf(p.Args)
+						if err != nil {
+							return nil, graph.Error(err)
+						}
+						thunk := request.loaders.threads.Load(p.Context, graph.PageKey[uint64]{Parent: post.ID, Page: page})
+						return func() (interface{}, error) {
+							threads, err := thunk()
+							if err != nil {
+								return nil, err
+							}
+							return graph.NewConnection(dto.NewComments(threads, request.viewer), page, commentID, count(p.Context, request.loaders.threadCounts, post.ID)), nil
+						}, nil
+					},
+				},
+				"version":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"updatedAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			}
+		}),
+	})
+
+	commentType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Comment",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"content":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"status":   &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "Whether the comment is approved, pending a moderator or spam"},
+				"depth":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"parentId": &graphql.Field{Type: graphql.ID},
+				"author": &graphql.Field{
+					Type: graphql.NewNonNull(userType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						comment := p.Source.(dto.Comment)
+						if comment.Author.ID != 0 {
+							return comment.Author, nil
+						}
+						return loadUser(p, comment.AuthorID), nil
+					},
+				},
+				"post": &graphql.Field{
+					Type: graphql.NewNonNull(postType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return loadPost(p, p.Source.(dto.Comment).PostID), nil
+					},
+				},
+				"replies": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(commentType))),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						replies := []dto.Comment{}
+						for _, reply := range p.Source.(dto.Comment).Replies {
+							replies = append(replies, *reply)
+						}
+						return replies, nil
+					},
+				},
+				"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"updatedAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			}
+		}),
+	})
+
+	userConnection = graph.ConnectionType(userType)
+	postConnection = graph.ConnectionType(postType)
+	commentConnection = graph.ConnectionType(commentType)
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"viewer": &graphql.Field{
+				Type:        userType,
+				Description: "The user behind the token of the request, null without one",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					uid := viewerID(requestOf(p).r)
+					if uid == 0 {
+						return nil, nil
+					}
+					return loadUser(p, uid), nil
+				},
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					uid, err := parseID(p.Args["id"], 32)
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					return loadUser(p, uint32(uid)), nil
+				},
+			},
+			"users": &graphql.Field{
+				Type: graphql.NewNonNull(userConnection),
+				Args: graph.ConnectionArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					request := requestOf(p)
+					page, err := graph.PageOf(p.Args)
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					users, err := models.FindUsersAfter(server.db(request.r), uint32(page.After), page.First+1)
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					return graph.NewConnection(dto.NewUsers(users, request.viewer), page, userID, server.total(request, &models.User{})), nil
+				},
+			},
+			"post": &graphql.Field{
+				Type: postType,
+				Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					postid, err := parseID(p.Args["id"], 64)
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					return loadPost(p, postid), nil
+				},
+			},
+			"posts": &graphql.Field{
+				Type: graphql.NewNonNull(postConnection),
+				Args: graph.ConnectionArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					request := requestOf(p)
+					page, err := graph.PageOf(p.Args)
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					posts, err := models.FindPostsAfter(server.db(request.r), page.After, page.First+1, models.PostLoad{})
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					return graph.NewConnection(dto.NewPosts(posts, request.viewer), page, postID, server.total(request, &models.Post{})), nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: server.graphqlMutations(postType, commentType)})
+}
+
+// Function graphqlMutations builds the mutations, each one checks the token, the rate limits and the input the way the
+// REST route making the same change does
+func (server *Server) graphqlMutations(postType, commentType *graphql.Object) *graphql.Object {
+	postInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "PostInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"content": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+	commentInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CommentInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"postId":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.ID)},
+			"content":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"parentId": &graphql.InputObjectFieldConfig{Type: graphql.ID, Description: "The comment this one replies to"},
+		},
+	})
+	version := &graphql.ArgumentConfig{Type: graphql.Int, Description: "The version the post was read at, the change fails if it was changed since, like with If-Match"}
+
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createPost": &graphql.Field{
+				Type: graphql.NewNonNull(postType),
+				Args: graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(postInput)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					request := requestOf(p)
+					uid, err := request.uid()
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					err = server.Limiter.Allow("create_post", request.r)
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+
+					post, err := server.publishPost(request.r, uid, postRequest(p.Args["input"], uid))
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					return dto.NewPost(*post, request.viewer), nil
+				},
+			},
+			"updatePost": &graphql.Field{
+				Type: graphql.NewNonNull(postType),
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(postInput)},
+					"version": version,
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					request := requestOf(p)
+					uid, err := request.uid()
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					postid, err := parseID(p.Args["id"], 64)
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+
+					post, err := server.authoredPost(request.r, uid, postid, "update")
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					postUpdated, err := server.replacePost(request.r, post, postRequest(p.Args["input"], uid), versionOf(p.Args))
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					return dto.NewPost(*postUpdated, request.viewer), nil
+				},
+			},
+			"deletePost": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.ID),
+				Description: "Deletes a post with its comments, reactions and bookmarks and returns its id",
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"version": version,
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					request := requestOf(p)
+					uid, err := request.uid()
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					postid, err := parseID(p.Args["id"], 64)
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+
+					post, err := server.authoredPost(request.r, uid, postid, "delete")
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					_, err = post.DeletePost(*server.db(request.r), postid, uid, versionOf(p.Args))
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					return p.Args["id"], nil
+				},
+			},
+			"createComment": &graphql.Field{
+				Type:        graphql.NewNonNull(commentType),
+				Description: "Comments on a post, the comment may be held for a moderator or treated as spam like on the REST API",
+				Args:        graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(commentInput)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					request := requestOf(p)
+					uid, err := request.uid()
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+
+					input := p.Args["input"].(map[string]interface{})
+					postid, err := parseID(input["postId"], 64)
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					comment := models.Comment{Content: input["content"].(string)}
+					if parent, ok := input["parentId"]; ok && parent != nil {
+						parentid, err := parseID(parent, 64)
+						if err != nil {
+							return nil, graph.Error(err)
+						}
+						comment.ParentID = &parentid
+					}
+
+					commentCreated, err := server.publishComment(request.r, uid, postid, comment)
+					if err != nil {
+						return nil, graph.Error(err)
+					}
+					return dto.NewComment(*commentCreated, request.viewer), nil
+				},
+			},
+		},
+	})
+}
+
+// Function private resolves a field of a user that only the Self and Admin views fill, null in the public view
+func private(field func(dto.User) string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if value := field(p.Source.(dto.User)); value != "" {
+			return value, nil
+		}
+		return nil, nil
+	}
+}
+
+// Function followCount resolves a follow count of a user, the counts of every user of a query are loaded together
+func followCount(field func(models.User) int64) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		thunk := requestOf(p).loaders.followCounts.Load(p.Context, p.Source.(dto.User).ID)
+		return func() (interface{}, error) {
+			user, err := thunk()
+			return field(user), err
+		}, nil
+	}
+}
+
+// Function loadUser resolves the user with the given id through the loader of the request, null when there is none
+func loadUser(p graphql.ResolveParams, uid uint32) func() (interface{}, error) {
+	request := requestOf(p)
+	thunk := request.loaders.users.Load(p.Context, uid)
+	return func() (interface{}, error) {
+		user, err := thunk()
+		if err != nil || user.ID == 0 {
+			return nil, err
+		}
+		return dto.NewUser(user, request.viewer), nil
+	}
+}
+
+// Function loadPost resolves the post with the given id through the loader of the request, null when there is none
+func loadPost(p graphql.ResolveParams, postid uint64) func() (interface{}, error) {
+	request := requestOf(p)
+	thunk := request.loaders.posts.Load(p.Context, postid)
+	return func() (interface{}, error) {
+		post, err := thunk()
+		if err != nil || post.ID == 0 {
+			return nil, err
+		}
+		return dto.NewPost(post, request.viewer), nil
+	}
+}
+
+// Function total counts every row of a table for the totalCount of a top level connection
+func (server *Server) total(request *graphqlRequest, model interface{}) func() func() (interface{}, error) {
+	return func() func() (interface{}, error) {
+		var total int64
+		err := server.db(request.r).Model(model).Count(&total).Error
+		return func() (interface{}, error) {
+			return total, err
+		}
+	}
+}
+
+// Function postRequest maps a PostInput onto the body of the REST routes, the author is the user behind the token
+func postRequest(input interface{}, uid uint32) dto.PostRequest {
+	fields := input.(map[string]interface{})
+	return dto.PostRequest{Title: fields["title"].(string), Content: fields["content"].(string), AuthorID: uid}
+}
+
+// Function versionOf reads the optional version argument of a mutation, 0 when the client sent none
+func versionOf(args map[string]interface{}) uint64 {
+	if version, ok := args["version"].(int); ok && version > 0 {
+		return uint64(version)
+	}
+	return 0
+}
+
+func userID(user dto.User) uint64 {
+	return uint64(user.ID)
+}
+
+func postID(post dto.Post) uint64 {
+	return post.ID
+}
+
+func commentID(comment dto.Comment) uint64 {
+	return comment.ID
+}
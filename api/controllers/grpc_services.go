@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/rpc"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/rpc/blogpb"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Function NewGRPCServer returns the gRPC server of the API with the blog services, the health service and reflection
+// registered. Its health service reports every service as serving until Run starts shutting down.
+func (server *Server) NewGRPCServer() *grpc.Server {
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(rpc.Interceptors()...))
+	blogpb.RegisterAuthServiceServer(grpcServer, authService{server: server})
+	blogpb.RegisterUserServiceServer(grpcServer, userService{server: server})
+	blogpb.RegisterPostServiceServer(grpcServer, postService{server: server})
+
+	server.grpcHealth = grpchealth.NewServer()
+	grpc_health_v1.RegisterHealthServer(grpcServer, server.grpcHealth)
+	reflection.Register(grpcServer)
+	return grpcServer
+}
+
+// authService, userService and postService serve the gRPC API through the helpers of the REST handlers
+type authService struct {
+	blogpb.UnimplementedAuthServiceServer
+	server *Server
+}
+
+type userService struct {
+	blogpb.UnimplementedUserServiceServer
+	server *Server
+}
+
+type postService struct {
+	blogpb.UnimplementedPostServiceServer
+	server *Server
+}
+
+func (service authService) Login(ctx context.Context, request *blogpb.LoginRequest) (*blogpb.LoginResponse, error) {
+	r := rpc.HTTPRequest(ctx)
+	err := service.server.Limiter.Allow("login", r)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := service.server.login(r, dto.Credentials{Email: request.GetEmail(), Password: request.GetPassword()})
+	if err != nil {
+		return nil, err
+	}
+	return &blogpb.LoginResponse{Token: token}, nil
+}
+
+func (service userService) CreateUser(ctx context.Context, request *blogpb.CreateUserRequest) (*blogpb.User, error) {
+	r := rpc.HTTPRequest(ctx)
+	err := service.server.Limiter.Allow("register", r)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := service.server.registerUser(r, dto.UserRequest{UserName: request.GetUserName(), Email: request.GetEmail(), Password: request.GetPassword()})
+	if err != nil {
+		return nil, err
+	}
+	// The new user is shown to themselves, the call is not authenticated yet
+	return userMessage(dto.NewUser(*user, dto.Viewer{ID: user.ID})), nil
+}
+
+func (service userService) GetUser(ctx context.Context, request *blogpb.GetUserRequest) (*blogpb.User, error) {
+	r := rpc.HTTPRequest(ctx)
+	user := models.User{}
+	userGotten, err := user.FindUser(service.server.db(r), request.GetId(), models.FullUserLoad)
+	if err != nil {
+		return nil, err
+	}
+	return userMessage(dto.NewUser(*userGotten, service.server.viewer(r))), nil
+}
+
+func (service userService) ListUsers(ctx context.Context, request *blogpb.ListUsersRequest) (*blogpb.ListUsersResponse, error) {
+	page, err := rpc.PageOf(request.GetPageSize(), request.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+
+	r := rpc.HTTPRequest(ctx)
+	users, err := models.FindUsersAfter(service.server.db(r), uint32(page.After), page.Size+1)
+	if err != nil {
+		return nil, err
+	}
+	users, next := rpc.Trim(users, page, func(user models.User) uint64 { return uint64(user.ID) })
+	err = models.LoadFollowCounts(service.server.db(r), users)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &blogpb.ListUsersResponse{NextPageToken: next}
+	for _, user := range dto.NewUsers(users, service.server.viewer(r)) {
+		response.Users = append(response.Users, userMessage(user))
+	}
+	return response, nil
+}
+
+func (service postService) CreatePost(ctx context.Context, request *blogpb.CreatePostRequest) (*blogpb.Post, error) {
+	uid, err := rpc.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := rpc.HTTPRequest(ctx)
+	err = service.server.Limiter.Allow("create_post", r)
+	if err != nil {
+		return nil, err
+	}
+
+	post, err := service.server.publishPost(r, uid, dto.PostRequest{Title: request.GetTitle(), Content: request.GetContent(), AuthorID: uid})
+	if err != nil {
+		return nil, err
+	}
+	return postMessage(dto.NewPost(*post, service.server.viewer(r))), nil
+}
+
+func (service postService) GetPost(ctx context.Context, request *blogpb.GetPostRequest) (*blogpb.Post, error) {
+	r := rpc.HTTPRequest(ctx)
+	post := models.Post{}
+	postReceived, err := post.FIndPostByID(*service.server.db(r), request.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return postMessage(dto.NewPost(*postReceived, service.server.viewer(r))), nil
+}
+
+func (service postService) ListPosts(ctx context.Context, request *blogpb.ListPostsRequest) (*blogpb.ListPostsResponse, error) {
+	page, err := rpc.PageOf(request.GetPageSize(), request.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+
+	r := rpc.HTTPRequest(ctx)
+	posts, err := models.FindPostsAfter(service.server.db(r), page.After, page.Size+1, models.FullPostLoad)
+	if err != nil {
+		return nil, err
+	}
+	posts, next := rpc.Trim(posts, page, func(post models.Post) uint64 { return post.ID })
+
+	response := &blogpb.ListPostsResponse{NextPageToken: next}
+	for _, post := range dto.NewPosts(posts, service.server.viewer(r)) {
+		response.Posts = append(response.Posts, postMessage(post))
+	}
+	return response, nil
+}
+
+func (service postService) UpdatePost(ctx context.Context, request *blogpb.UpdatePostRequest) (*blogpb.Post, error) {
+	uid, err := rpc.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := rpc.HTTPRequest(ctx)
+
+	post, err := service.server.authoredPost(r, uid, request.GetId(), "update")
+	if err != nil {
+		return nil, err
+	}
+	postUpdated, err := service.server.replacePost(r, post, dto.PostRequest{Title: request.GetTitle(), Content: request.GetContent(), AuthorID: uid}, request.GetVersion())
+	if err != nil {
+		return nil, err
+	}
+	return postMessage(dto.NewPost(*postUpdated, service.server.viewer(r))), nil
+}
+
+func (service postService) DeletePost(ctx context.Context, request *blogpb.DeletePostRequest) (*blogpb.DeletePostResponse, error) {
+	uid, err := rpc.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := rpc.HTTPRequest(ctx)
+
+	post, err := service.server.authoredPost(r, uid, request.GetId(), "delete")
+	if err != nil {
+		return nil, err
+	}
+	_, err = post.DeletePost(*service.server.db(r), request.GetId(), uid, request.GetVersion())
+	if err != nil {
+		return nil, err
+	}
+	return &blogpb.DeletePostResponse{}, nil
+}
+
+// Function userMessage maps a user as the REST API shows it to its message, so both APIs hide the same fields
+func userMessage(user dto.User) *blogpb.User {
+	return &blogpb.User{
+		Id:             user.ID,
+		UserName:       user.UserName,
+		Email:          user.Email,
+		Role:           user.Role,
+		FollowersCount: user.FollowersCount,
+		FollowingCount: user.FollowingCount,
+		Version:        user.Version,
+		CreatedAt:      timestamppb.New(user.CreatedAt),
+		UpdatedAt:      timestamppb.New(user.UpdatedAt),
+	}
+}
+
+// Function postMessage maps a post as the REST API shows it to its message
+func postMessage(post dto.Post) *blogpb.Post {
+	return &blogpb.Post{
+		Id:           post.ID,
+		Title:        post.Title,
+		Content:      post.Content,
+		Author:       userMessage(post.Author),
+		AuthorId:     post.AuthorID,
+		CommentCount: post.CommentCount,
+		Version:      post.Version,
+		CreatedAt:    timestamppb.New(post.CreatedAt),
+		UpdatedAt:    timestamppb.New(post.UpdatedAt),
+	}
+}
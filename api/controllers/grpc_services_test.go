@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+	"database/sql/driver"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/rpc/blogpb"
+	"github.com/jackc/pgx/v5/pgconn"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// userColumns are the columns of the users table in the order SELECT * returns them
+var userColumns = []string{"id", "user_name", "email", "password", "role", "version", "created_at", "updated_at"}
+
+// usersTable keeps the users a test registers, it answers the statements of registration and login
+type usersTable struct {
+	rows []map[string]driver.Value
+}
+
+func (table *usersTable) handle(query string, args []driver.Value) (fakeResult, error) {
+	switch {
+	case strings.HasPrefix(query, `INSERT INTO "users"`):
+		row := map[string]driver.Value{}
+		for i, column := range columnsOf(query) {
+			row[column] = args[i]
+		}
+		for _, existing := range table.rows {
+			if existing["email"] == row["email"] {
+				return fakeResult{}, &pgconn.PgError{Code: "23505", TableName: "users", ConstraintName: "users_email_key"}
+			}
+		}
+		row["id"] = int64(len(table.rows) + 1)
+		table.rows = append(table.rows, row)
+
+		_, returning, _ := strings.Cut(query, "RETURNING ")
+		result := fakeResult{rows: [][]driver.Value{{}}}
+		for _, column := range strings.Split(returning, ",") {
+			column = strings.Trim(strings.TrimSpace(column), `"`)
+			result.columns = append(result.columns, column)
+			result.rows[0] = append(result.rows[0], row[column])
+		}
+		return result, nil
+	case strings.HasPrefix(query, `SELECT * FROM "users" WHERE email = $1`):
+		result := fakeResult{columns: userColumns}
+		for _, row := range table.rows {
+			if row["email"] == args[0] {
+				values := []driver.Value{}
+				for _, column := range userColumns {
+					values = append(values, row[column])
+				}
+				result.rows = append(result.rows, values)
+			}
+		}
+		return result, nil
+	}
+	return fakeResult{}, nil
+}
+
+// Function dialGRPC serves the gRPC API of server over an in-memory listener and returns a connection to it
+func dialGRPC(t *testing.T, server *Server) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := server.NewGRPCServer()
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("cannot dial the gRPC server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPCRegisterThenLogin(t *testing.T) {
+	t.Setenv("API_SECRET", "test-secret")
+	table := &usersTable{}
+	conn := dialGRPC(t, &Server{DB: fakeDB(t, table.handle)})
+	users := blogpb.NewUserServiceClient(conn)
+	authClient := blogpb.NewAuthServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := users.CreateUser(ctx, &blogpb.CreateUserRequest{UserName: "ada", Email: "ada@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if user.GetId() == 0 || user.GetEmail() != "ada@example.com" {
+		t.Fatalf("CreateUser returned %v", user)
+	}
+	stored, _ := table.rows[0]["password"].(string)
+	if err := models.VerifyPassword(stored, "correct horse"); err != nil {
+		t.Fatalf("the password was not stored as a bcrypt hash: %v", err)
+	}
+
+	login, err := authClient.Login(ctx, &blogpb.LoginRequest{Email: "ada@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	uid, err := auth.TokenID(ctx, login.GetToken())
+	if err != nil || uid != user.GetId() {
+		t.Fatalf("the token is for user %d (%v), want %d", uid, err, user.GetId())
+	}
+
+	_, err = authClient.Login(ctx, &blogpb.LoginRequest{Email: "ada@example.com", Password: "wrong horse"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Login with a wrong password: got %v, want Unauthenticated", err)
+	}
+	_, err = authClient.Login(ctx, &blogpb.LoginRequest{Email: "bob@example.com", Password: "correct horse"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Login with an unknown email: got %v, want Unauthenticated", err)
+	}
+}
+
+func TestGRPCRegisterTakenEmail(t *testing.T) {
+	table := &usersTable{}
+	conn := dialGRPC(t, &Server{DB: fakeDB(t, table.handle)})
+	users := blogpb.NewUserServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := users.CreateUser(ctx, &blogpb.CreateUserRequest{UserName: "ada", Email: "ada@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	_, err = users.CreateUser(ctx, &blogpb.CreateUserRequest{UserName: "ada2", Email: "ada@example.com", Password: "correct horse"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("CreateUser with a taken email: got %v, want AlreadyExists", err)
+	}
+}
+
+func TestGRPCRegisterInvalid(t *testing.T) {
+	conn := dialGRPC(t, &Server{DB: fakeDB(t, (&usersTable{}).handle)})
+	users := blogpb.NewUserServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := users.CreateUser(ctx, &blogpb.CreateUserRequest{UserName: "ada", Email: "not an email", Password: "correct horse"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("CreateUser with an invalid email: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestGRPCTokens(t *testing.T) {
+	t.Setenv("API_SECRET", "test-secret")
+	conn := dialGRPC(t, &Server{DB: fakeDB(t, (&usersTable{}).handle)})
+	posts := blogpb.NewPostServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := posts.CreatePost(ctx, &blogpb.CreatePostRequest{Title: "Title", Content: "Content"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("CreatePost without a token: got %v, want Unauthenticated", err)
+	}
+
+	invalid := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer not-a-token")
+	_, err = posts.GetPost(invalid, &blogpb.GetPostRequest{Id: 1})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("GetPost with an invalid token: got %v, want Unauthenticated", err)
+	}
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/health"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+)
+
+// Liveness is the body of the liveness probe
+type Liveness struct {
+	Status string `json:"status"`
+}
+
+// Function Healthz answers as long as the process can serve requests, it never looks at dependencies
+func (server *Server) Healthz(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, http.StatusOK, Liveness{Status: health.StatusOK})
+}
+
+// Function Readyz reports whether the instance should receive traffic, with the outcome of each check
+func (server *Server) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := server.Health.Run(r.Context())
+	if report.Status != health.StatusOK {
+		responses.JSON(w, http.StatusServiceUnavailable, report)
+		return
+	}
+	responses.JSON(w, http.StatusOK, report)
+}
+
+// Function registerHealthChecks sets up the checks readiness depends on: the database, its migrations and the notifier
+func (server *Server) registerHealthChecks() {
+	server.Health = health.NewChecker()
+
+	server.Health.Register("database", func(ctx context.Context) error {
+		sqlDB, err := server.DB.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+
+	server.Health.Register("migrations", health.Cached(func(ctx context.Context) error {
+		pending, err := models.PendingMigrations(server.DB.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+		}
+		return nil
+	}, time.Minute))
+
+	server.Health.Register("notifier", func(ctx context.Context) error {
+		return server.Notifier.Healthy()
+	})
+}
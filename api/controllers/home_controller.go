@@ -4,8 +4,19 @@ import (
 	"net/http"
 
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
 )
 
 func (server *Server) Home(w http.ResponseWriter, r *http.Request) {
 	responses.JSON(w, http.StatusOK, "Welcome to the API")
 }
+
+// Function NotFound answers requests for paths that no route matches
+func (server *Server) NotFound(w http.ResponseWriter, r *http.Request) {
+	responses.ERROR(w, http.StatusNotFound, apperror.NotFound("route_not_found", "No route matches "+r.URL.Path))
+}
+
+// Function MethodNotAllowed answers requests whose path matches a route but whose method does not
+func (server *Server) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	responses.ERROR(w, http.StatusMethodNotAllowed, apperror.New(http.StatusMethodNotAllowed, "method_not_allowed", r.Method+" is not allowed on "+r.URL.Path))
+}
@@ -1,61 +1,84 @@
 package controllers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/metrics"
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/formaterror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 func (server *Server) Login(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
-	user := models.User{}
-	err = json.Unmarshal(body, &user)
+	credentials := dto.Credentials{}
+	err = validation.Decode(body, &credentials)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
-	user.Prepare()
-	err = user.Validate("login")
+	token, err := server.login(r, credentials)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		responses.ERROR(w, http.StatusUnauthorized, err)
 		return
 	}
+	responses.JSON(w, http.StatusOK, token)
 
-	token, err := server.SignIn(user.Email, user.Password)
+}
+
+// Function login checks credentials and returns a token for their user. The REST and gRPC APIs both log users in
+// through it so they count logins and hide unknown emails the same way.
+func (server *Server) login(r *http.Request, credentials dto.Credentials) (string, error) {
+	err := validation.Struct(credentials)
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusUnprocessableEntity, formattedError)
-		return
+		return "", err
 	}
-	responses.JSON(w, http.StatusOK, token)
 
+	// Emails are stored the way Prepare cleans them up
+	user := models.User{Email: credentials.Email}
+	user.Prepare()
+	token, err := server.SignIn(r.Context(), user.Email, credentials.Password)
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		// Unknown emails and wrong passwords look the same so accounts cannot be probed
+		metrics.Logins.WithLabelValues("failure").Inc()
+		return "", apperror.Unauthorized("invalid_credentials", "Incorrect details")
+	}
+	if err != nil {
+		metrics.Logins.WithLabelValues("error").Inc()
+		return "", formaterror.FormatError(err)
+	}
+	metrics.Logins.WithLabelValues("success").Inc()
+	return token, nil
 }
 
-func (server *Server) SignIn(email, password string) (string, error) {
+func (server *Server) SignIn(ctx context.Context, email, password string) (string, error) {
 
 	var err error
 
 	user := models.User{}
 
-	err = server.DB.Debug().Model(models.User{}).Where("email = ?", email).Take(&user).Error
+	err = server.DB.WithContext(ctx).Model(models.User{}).Where("email = ?", email).Take(&user).Error
 	if err != nil {
 		return "", err
 	}
 
 	err = models.VerifyPassword(user.Password, password)
-	if err != nil && err == bcrypt.ErrMismatchedHashAndPassword {
+	if err != nil {
 		return "", err
 	}
 
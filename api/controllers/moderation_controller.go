@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/logging"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/spam"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/gorilla/mux"
+)
+
+// Function moderator returns the user behind the token, failing when the user is not a moderator
+func (server *Server) moderator(r *http.Request) (*models.User, error) {
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		return &models.User{}, apperror.Unauthorized("invalid_token", "Unauthorized")
+	}
+
+	user := models.User{}
+	err = server.db(r).Model(models.User{}).Where("id = ?", uid).Take(&user).Error
+	if err != nil {
+		return &models.User{}, apperror.Unauthorized("invalid_token", "Unauthorized")
+	}
+
+	if !user.IsModerator() {
+		return &models.User{}, apperror.Forbidden("not_moderator", "Only moderators can manage the moderation queue")
+	}
+	return &user, nil
+}
+
+func (server *Server) GetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	_, err := server.moderator(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusForbidden, err)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = models.CommentPending
+	case models.CommentPending, models.CommentApproved, models.CommentRejected, models.CommentSpam:
+	default:
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_status", "Invalid status"))
+		return
+	}
+
+	page, perPage := paginate(r)
+
+	comment := models.Comment{}
+	comments, total, err := comment.FindCommentsByStatus(server.db(r), status, page, perPage)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.List(w, r, http.StatusOK, Page{Data: dto.NewComments(comments, server.viewer(r)), Page: page, PerPage: perPage, Total: total})
+}
+
+func (server *Server) ApproveComment(w http.ResponseWriter, r *http.Request) {
+	server.moderateComment(w, r, models.CommentApproved)
+}
+
+func (server *Server) RejectComment(w http.ResponseWriter, r *http.Request) {
+	server.moderateComment(w, r, models.CommentRejected)
+}
+
+func (server *Server) MarkCommentSpam(w http.ResponseWriter, r *http.Request) {
+	server.moderateComment(w, r, models.CommentSpam)
+}
+
+// Function moderateComment applies a moderator decision to a comment, approvals and spam reports also train the spam filter
+func (server *Server) moderateComment(w http.ResponseWriter, r *http.Request, status string) {
+	vars := mux.Vars(r)
+	commentid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+
+	_, err = server.moderator(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusForbidden, err)
+		return
+	}
+
+	// Checks if the comment exist
+	comment := models.Comment{}
+	err = server.db(r).Model(models.Comment{}).Where("id = ?", commentid).Take(&comment).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, apperror.NotFound("comment_not_found", "Comment not found"))
+		return
+	}
+
+	previousStatus := comment.Status
+	commentModerated, err := comment.UpdateCommentStatus(server.db(r), status)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The spam filter only learns decisions that are stored
+	if status != models.CommentRejected {
+		server.afterCommit(r, func() { server.trainSpamFilter(r, *commentModerated, status) })
+	}
+
+	// Readers are only told about a comment once it is published
+	if status == models.CommentApproved && previousStatus != models.CommentApproved {
+		server.afterCommit(r, func() { server.notifyComment(r, commentModerated) })
+	}
+	responses.JSON(w, http.StatusOK, dto.NewComment(*commentModerated, server.viewer(r)))
+}
+
+// Function trainSpamFilter teaches the spam filter the decision stored for a comment, approved or spam. A decision the
+// filter learnt before from the same comment is forgotten first so the comment never counts twice, and repeating a
+// decision teaches nothing. Failures are logged, the decision itself is already stored.
+func (server *Server) trainSpamFilter(r *http.Request, comment models.Comment, status string) {
+	trainer, ok := server.Spam.(spam.Trainer)
+	if !ok || comment.TrainedAs == status {
+		return
+	}
+
+	log := logging.FromContext(r.Context())
+	if !server.forgetSpamLesson(r, comment) {
+		return
+	}
+	err := trainer.Train(comment.Content, status == models.CommentSpam)
+	if err != nil {
+		log.ErrorContext(r.Context(), "cannot train the spam filter", "comment_id", comment.ID, "error", err.Error())
+		status = ""
+	}
+	err = comment.UpdateTrainedAs(server.DB.WithContext(r.Context()), status)
+	if err != nil {
+		log.ErrorContext(r.Context(), "cannot record what the spam filter learnt", "comment_id", comment.ID, "error", err.Error())
+	}
+}
+
+// Function forgetSpamLesson makes the spam filter forget the decision it learnt from the content of a comment, if any,
+// and reports whether it did. Failures are logged.
+func (server *Server) forgetSpamLesson(r *http.Request, comment models.Comment) bool {
+	trainer, ok := server.Spam.(spam.Trainer)
+	if !ok || comment.TrainedAs == "" {
+		return true
+	}
+
+	err := trainer.Forget(comment.Content, comment.TrainedAs == models.CommentSpam)
+	if err != nil {
+		logging.FromContext(r.Context()).ErrorContext(r.Context(), "cannot train the spam filter", "comment_id", comment.ID, "error", err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// recordingTrainer is a spam filter that gives every content the same score and records what it is taught
+type recordingTrainer struct {
+	score   float64
+	lessons []string
+}
+
+func (trainer *recordingTrainer) Score(content string) float64 {
+	return trainer.score
+}
+
+func (trainer *recordingTrainer) Train(content string, isSpam bool) error {
+	trainer.lessons = append(trainer.lessons, "train "+label(isSpam))
+	return nil
+}
+
+func (trainer *recordingTrainer) Forget(content string, isSpam bool) error {
+	trainer.lessons = append(trainer.lessons, "forget "+label(isSpam))
+	return nil
+}
+
+func label(isSpam bool) string {
+	if isSpam {
+		return "spam"
+	}
+	return "ham"
+}
+
+func TestModerationTrainsTheSpamFilter(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		trainedAs string
+		path      string
+		want      []string
+	}{
+		{name: "approve", path: "/v1/admin/comments/1/approve", want: []string{"train ham"}},
+		{name: "mark spam", path: "/v1/admin/comments/1/spam", want: []string{"train spam"}},
+		{name: "reject", path: "/v1/admin/comments/1/reject", want: nil},
+		{name: "approve again", trainedAs: "approved", path: "/v1/admin/comments/1/approve", want: nil},
+		{name: "approved then spam", trainedAs: "approved", path: "/v1/admin/comments/1/spam", want: []string{"forget ham", "train spam"}},
+		{name: "spam then approved", trainedAs: "spam", path: "/v1/admin/comments/1/approve", want: []string{"forget spam", "train ham"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tables := blogTables()
+			tables.rows["users"].rows[0][3] = "moderator"
+			comments := tables.rows["comments"]
+			comments.columns = append(comments.columns, "trained_as")
+			comments.rows[0] = append(comments.rows[0], test.trainedAs)
+			tables.rows["comments"] = comments
+
+			trainer := &recordingTrainer{}
+			server := testServer(t, tables.handle, func(server *Server) { server.Spam = trainer })
+			w := serve(server, http.MethodPut, test.path, tokenFor(t, 1), "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("got %d, want 200: %s", w.Code, w.Body)
+			}
+			if !reflect.DeepEqual(trainer.lessons, test.want) {
+				t.Errorf("the spam filter was taught %q, want %q", trainer.lessons, test.want)
+			}
+			if len(test.want) > 0 && !tables.ran(`SET "trained_as"`) {
+				t.Errorf("what the spam filter learnt was not recorded: %q", tables.statements)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
+	"github.com/gorilla/mux"
+)
+
+func (server *Server) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+
+	page, perPage := paginate(r)
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, total, err := models.FindNotifications(server.db(r), uid, unreadOnly, page, perPage)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.List(w, r, http.StatusOK, Page{Data: dto.NewNotifications(notifications), Page: page, PerPage: perPage, Total: total})
+}
+
+func (server *Server) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+
+	_, err = models.MarkNotificationsRead(server.db(r), uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.NoContent(w)
+}
+
+func (server *Server) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	notificationid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+
+	_, err = models.MarkNotificationsRead(server.db(r), uid, notificationid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.NoContent(w)
+}
+
+// Function StreamNotifications pushes new notifications to the client as Server-Sent Events until the client disconnects.
+// Browsers cannot set headers on an EventSource, so the token can be passed in the token query parameter.
+func (server *Server) StreamNotifications(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+
+	// A stream never ends, the batch running it would never answer
+	if batchOf(r) != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("stream_in_batch", "Streams cannot be part of a batch"))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok || server.Notifier == nil {
+		responses.ERROR(w, http.StatusInternalServerError, apperror.Internal("streaming_unsupported", "Streaming is not supported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	notifications, unsubscribe := server.Notifier.Hub.Subscribe(uid)
+	defer unsubscribe()
+
+	// A comment is sent regularly so proxies do not close an idle connection
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-server.stopping:
+			return
+
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+
+		case notification := <-notifications:
+			data, err := json.Marshal(dto.NewNotification(notification))
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", notification.ID, notification.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+func (server *Server) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+
+	preferences, err := models.FindNotificationPreferences(server.db(r), uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, preferences)
+}
+
+func (server *Server) UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	preferences := []models.NotificationPreference{}
+	err = validation.Decode(body, &preferences)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// A type listed twice would make the upsert change the same row twice, which Postgres refuses
+	fields := validation.Errors{}
+	seen := map[string]bool{}
+	for i := range preferences {
+		name := fmt.Sprintf("[%d]", i)
+		if err := validation.Struct(preferences[i]); err != nil {
+			for _, field := range err.(validation.Errors) {
+				fields = append(fields, validation.FieldError{Field: name + "." + field.Field, Message: field.Message})
+			}
+			continue
+		}
+		if seen[preferences[i].Type] {
+			fields = append(fields, validation.FieldError{Field: name + ".type", Message: "type must be listed only once"})
+		}
+		seen[preferences[i].Type] = true
+		preferences[i].UserID = uid
+	}
+	if len(fields) > 0 {
+		responses.ERROR(w, http.StatusUnprocessableEntity, apperror.Validation("validation_failed", "The request is invalid", fields...))
+		return
+	}
+
+	err = models.SaveNotificationPreferences(server.db(r), preferences)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	preferencesSaved, err := models.FindNotificationPreferences(server.db(r), uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, preferencesSaved)
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUpdatePreferencesRefusesDuplicateTypes(t *testing.T) {
+	tables := blogTables()
+	server := testServer(t, tables.handle)
+
+	w := serve(server, http.MethodPut, "/v1/notifications/preferences", tokenFor(t, 1), `[{"type": "comment", "email": true}, {"type": "follow", "email": true}, {"type": "comment", "email": false}]`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("got %d, want 422: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), `"field":"[2].type"`) {
+		t.Errorf("the problem does not point at the repeated type: %s", w.Body)
+	}
+	if tables.ran("INSERT INTO") {
+		t.Errorf("the preferences were saved: %q", tables.statements)
+	}
+
+	w = serve(server, http.MethodPut, "/v1/notifications/preferences", tokenFor(t, 1), `[{"type": "comment", "email": true}, {"type": "follow", "email": true}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// Page wraps a list response with the pagination details the client needs to request the next page
+type Page struct {
+	Data    interface{} `json:"data"`
+	Page    int         `json:"page"`
+	PerPage int         `json:"per_page"`
+	Total   int64       `json:"total"`
+}
+
+// CursorPage wraps a list response paginated with an opaque cursor, NextCursor is empty on the last page
+type CursorPage struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor"`
+}
+
+// Function paginate reads the page and per_page query parameters, falling back to sane defaults when they are missing or invalid
+func paginate(r *http.Request) (int, int) {
+	keys := r.URL.Query()
+
+	page, err := strconv.Atoi(keys.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	perPage, err := strconv.Atoi(keys.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	return page, perPage
+}
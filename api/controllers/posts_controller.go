@@ -1,66 +1,149 @@
 package controllers
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strconv"
 
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/fieldset"
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/patch"
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/formaterror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
 	"github.com/gorilla/mux"
 )
 
+// postFields is what the fields and expand parameters select from on the post routes
+var postFields = fieldset.Resource{
+	Type: dto.Post{},
+	Expansions: map[string][]string{
+		"author":        {"author"},
+		"comment_count": {"comment_count"},
+		"reactions":     {"reactions", "viewer_reactions"},
+	},
+}
+
+// Function postLoad works out what a post query loads to show the selected fields. The id, version and update time
+// are always loaded, the related resources need the id and the validators of a single post the other two.
+func postLoad(selection fieldset.Selection) models.PostLoad {
+	required := []string{"id", "version", "updated_at"}
+	load := models.PostLoad{CommentCount: selection.Expands("comment_count")}
+	if selection.Expands("author") {
+		required = append(required, "author_id")
+		load.AuthorColumns = selection.Columns("author", models.UserColumns, "id")
+	}
+	load.Columns = selection.Columns("", models.PostColumns, required...)
+	return load
+}
+
+// postPatch holds the fields of a post a PATCH may change
+type postPatch struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
 func (server *Server) CreatePost(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
-	post := models.Post{}
-	err = json.Unmarshal(body, &post)
+	request := dto.PostRequest{}
+	err = validation.Decode(body, &request)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
-	post.Prepare()
-	err = post.ValidatePost()
+	uid, err := auth.ExtractTokenID(r)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
 		return
 	}
 
-	uid, err := auth.ExtractTokenID(r)
+	postCreated, err := server.publishPost(r, uid, request)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
 
+	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.URL.Path, postCreated.ID))
+	responses.JSON(w, http.StatusCreated, dto.NewPost(*postCreated, server.viewer(r)))
+}
+
+// Function publishPost checks and stores a new post of the user uid, then tells their followers about it. The REST and
+// GraphQL APIs both create posts through it so they validate and authorize them the same way.
+func (server *Server) publishPost(r *http.Request, uid uint32, request dto.PostRequest) (*models.Post, error) {
+	post := request.Model()
+	post.Prepare()
+	err := post.ValidatePost()
+	if err != nil {
+		return nil, err
+	}
+
 	if uid != post.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New(http.StatusText(http.StatusUnauthorized)))
-		return
+		return nil, apperror.Forbidden("not_post_author", "You can only publish posts as yourself")
 	}
 
-	postCreated, err := post.SavePost(server.DB)
+	postCreated, err := post.SavePost(server.db(r))
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusInternalServerError, formattedError)
-		return
+		return nil, formaterror.FormatError(err)
 	}
 
-	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.URL.Path, postCreated.ID))
-	responses.JSON(w, http.StatusOK, postCreated)
+	server.afterCommit(r, func() {
+		server.Notifier.NotifyFollowers(models.NotificationPostPublished, postCreated.AuthorID, &postCreated.ID)
+	})
+	return postCreated, nil
+}
+
+// Function authoredPost loads the post postid the user uid is about to change, only its author may change it. action
+// says what the change is in the error the other users get.
+func (server *Server) authoredPost(r *http.Request, uid uint32, postid uint64, action string) (models.Post, error) {
+	post := models.Post{}
+	err := server.db(r).Model(models.Post{}).Where("id = ?", postid).Take(&post).Error
+	if err != nil {
+		return post, apperror.NotFound("post_not_found", "Post not found")
+	}
+
+	if uid != post.AuthorID {
+		return post, apperror.Forbidden("not_post_author", fmt.Sprintf("You can only %s your own posts", action))
+	}
+	return post, nil
+}
+
+// Function replacePost replaces the title and content of a post with the ones of the request, version is the version the
+// client expects the post to be at, 0 when it expects none. The REST and GraphQL APIs both update posts through it.
+func (server *Server) replacePost(r *http.Request, post models.Post, request dto.PostRequest, version uint64) (*models.Post, error) {
+	postUpdate := request.Model()
+	postUpdate.Prepare()
+	err := postUpdate.ValidatePost()
+	if err != nil {
+		return nil, err
+	}
+
+	postUpdate.ID = post.ID // this is important to tell the model the post id to update, the other update field are set above
+	postUpdated, err := postUpdate.UpdatePost(*server.db(r), version)
+	if err != nil {
+		return nil, formaterror.FormatError(err)
+	}
+	return postUpdated, nil
 }
 
 func (server *Server) GetPost(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+
+	selection, err := fieldset.Parse(r.URL.Query(), postFields)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
@@ -68,23 +151,44 @@ func (server *Server) GetPost(w http.ResponseWriter, r *http.Request) {
 
 	post := models.Post{}
 
-	postReceived, err := post.FIndPostByID(*server.DB, postid)
+	postReceived, err := post.FindPost(*server.db(r), postid, postLoad(selection))
 	if err != nil {
-		responses.ERROR(w, http.StatusInternalServerError, err)
+		responses.ERROR(w, http.StatusInternalServerError, formaterror.FormatError(err))
 		return
 	}
-	responses.JSON(w, http.StatusOK, postReceived)
+	posts := []models.Post{*postReceived}
+	if selection.Expands("reactions") {
+		err = models.LoadReactions(server.db(r), posts, viewerID(r))
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	responses.Versioned(w, r, http.StatusOK, selection.Apply(dto.NewPost(posts[0], server.viewer(r))), posts[0].Version)
 }
 
 func (server *Server) GetPosts(w http.ResponseWriter, r *http.Request) {
+	selection, err := fieldset.Parse(r.URL.Query(), postFields)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
 	post := models.Post{}
 
-	posts, err := post.Author.FindAllUsers(server.DB)
+	posts, err := post.FIndAllPosts(server.db(r), postLoad(selection))
 	if err != nil {
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
-	responses.JSON(w, http.StatusOK, posts)
+	if selection.Expands("reactions") {
+		err = models.LoadReactions(server.db(r), *posts, viewerID(r))
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	responses.List(w, r, http.StatusOK, selection.Apply(dto.NewPosts(*posts, server.viewer(r))))
 }
 
 func (server *Server) UpdatePost(w http.ResponseWriter, r *http.Request) {
@@ -93,67 +197,113 @@ func (server *Server) UpdatePost(w http.ResponseWriter, r *http.Request) {
 	// Checks if the post id is valid
 	postid, err := strconv.ParseUint(vars["id"], 10, 64)
 	if err != nil {
-		responses.ERROR(w, http.StatusBadRequest, err)
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
 		return
 	}
 
 	// Checks whether the auth token is valid and gets the user id from it
 	userid, err := auth.ExtractTokenID(r)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorised"))
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
 		return
 	}
 
-	// Checks if the post exist
-	post := models.Post{}
-	err = server.DB.Debug().Model(models.Post{}).Where("id = ?", postid).Take(&post).Error
+	// Checks that the post exists and belongs to the user
+	post, err := server.authoredPost(r, userid, postid, "update")
 	if err != nil {
-		responses.ERROR(w, http.StatusNotFound, errors.New("Post not found"))
+		responses.ERROR(w, http.StatusNotFound, err)
 		return
 	}
 
-	// Checks attempts to trying updating post that does not belong to hom/her
-	if userid != post.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+	// Checks the version the client last read, when it sent one
+	version, err := expectedVersion(r, post.Version)
+	if err != nil {
+		responses.ERROR(w, http.StatusPreconditionFailed, err)
 		return
 	}
 
 	// Reading the posted data
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
 	// Start processing thr requested data
-	postUpdate := models.Post{}
-	err = json.Unmarshal(body, &postUpdate)
+	request := dto.PostRequest{}
+	err = validation.Decode(body, &request)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	postUpdated, err := server.replacePost(r, post, request, version)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
+	responses.Versioned(w, r, http.StatusOK, dto.NewPost(*postUpdated, server.viewer(r)), postUpdated.Version)
+}
 
-	// Confirms that the user id is the same as the on gotten from the token
-	if userid != post.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+// Function PatchPost changes only the fields named in a merge patch or JSON patch, only those fields are validated
+func (server *Server) PatchPost(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Accept-Patch", patch.Accepted)
+
+	vars := mux.Vars(r)
+	postid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
 		return
 	}
 
-	postUpdate.Prepare()
-	err = postUpdate.ValidatePost()
+	userid, err := auth.ExtractTokenID(r)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
 		return
 	}
 
-	postUpdate.ID = post.ID // this is important to tell the model the post id to update, the other update field are set above
-	postUpdated, err := postUpdate.UpdatePost(*server.DB)
+	post, err := server.authoredPost(r, userid, postid, "update")
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+
+	version, err := expectedVersion(r, post.Version)
+	if err != nil {
+		responses.ERROR(w, http.StatusPreconditionFailed, err)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	fields := postPatch{Title: post.Title, Content: post.Content}
+	changed, err := patch.Apply(r.Header.Get("Content-Type"), body, &fields)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	postUpdate := models.Post{Title: fields.Title, Content: fields.Content, AuthorID: post.AuthorID}
+	postUpdate.Prepare()
+	if len(changed) > 0 {
+		err = validation.Struct(postUpdate, changed...)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
+
+	postUpdate.ID = post.ID
+	postUpdated, err := postUpdate.PatchPost(*server.db(r), changed, version)
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		responses.ERROR(w, http.StatusInternalServerError, formaterror.FormatError(err))
 		return
 	}
-	responses.JSON(w, http.StatusOK, postUpdated)
+	responses.Versioned(w, r, http.StatusOK, dto.NewPost(*postUpdated, server.viewer(r)), postUpdated.Version)
 }
 
 func (server *Server) DeletePost(w http.ResponseWriter, r *http.Request) {
@@ -162,31 +312,36 @@ func (server *Server) DeletePost(w http.ResponseWriter, r *http.Request) {
 	// Insuring the post id given is valid
 	postid, err := strconv.ParseUint(vars["id"], 10, 64)
 	if err != nil {
-		responses.ERROR(w, http.StatusBadRequest, err)
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
 		return
 	}
 
 	// Checks if user is authenticated
 	userid, err := auth.ExtractTokenID(r)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
 		return
 	}
 
-	// Checking if the post exist
-	post := models.Post{}
-	err = server.DB.Debug().Model(models.Post{}).Where("id = ?", postid).Take(&post).Error
+	// Checking if the post exist and the authenticated user is the owner of the post
+	post, err := server.authoredPost(r, userid, postid, "delete")
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+
+	version, err := expectedVersion(r, post.Version)
 	if err != nil {
-		responses.ERROR(w, http.StatusNotFound, errors.New("post not found"))
+		responses.ERROR(w, http.StatusPreconditionFailed, err)
 		return
 	}
 
-	// Checking if the authenticated user is the owner of the post
-	if userid != post.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+	_, err = post.DeletePost(*server.db(r), postid, userid, version)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, formaterror.FormatError(err))
 		return
 	}
 
 	w.Header().Set("Entity", fmt.Sprintf("%d", postid))
-	responses.JSON(w, http.StatusOK, "")
+	responses.NoContent(w)
 }
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpdateStalePost(t *testing.T) {
+	server := testServer(t, blogTables().handle)
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		r := httptest.NewRequest(method, "/v1/posts/1", strings.NewReader(`{"title": "Title", "content": "Content", "author_id": 1}`))
+		r.Header.Set("Content-Type", "application/json")
+		if method == http.MethodPatch {
+			r.Header.Set("Content-Type", "application/merge-patch+json")
+		}
+		r.Header.Set("Authorization", "Bearer "+tokenFor(t, 1))
+		r.Header.Set("If-Match", `"v7-5f1c0e2ab94d7c61"`)
+		w := httptest.NewRecorder()
+		server.Router.ServeHTTP(w, r)
+
+		if w.Code != http.StatusPreconditionFailed || !strings.Contains(w.Body.String(), `"code":"stale_version"`) {
+			t.Errorf("%s with a stale If-Match: got %d %s, want 412 stale_version", method, w.Code, w.Body)
+		}
+	}
+}
+
+func TestGetPostWithFields(t *testing.T) {
+	tables := blogTables()
+	server := testServer(t, tables.handle)
+
+	w := serve(server, http.MethodGet, "/v1/posts/1?fields=title,author.user_name", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200: %s", w.Code, w.Body)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("cannot decode %s: %v", w.Body, err)
+	}
+	want := map[string]interface{}{
+		"title":  "Title",
+		"author": map[string]interface{}{"user_name": "ada"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Only the selected columns are loaded, with the ones the handler needs to find the author and tag the response
+	if !tables.ran(`SELECT "id","version","updated_at","author_id","title" FROM "posts"`) || !tables.ran(`SELECT "id","user_name" FROM "users"`) {
+		t.Errorf("other columns than the selected ones were loaded: %v", tables.statements)
+	}
+}
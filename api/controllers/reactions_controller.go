@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/gorilla/mux"
+)
+
+func (server *Server) AddReaction(w http.ResponseWriter, r *http.Request) {
+	server.react(w, r, true)
+}
+
+func (server *Server) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	server.react(w, r, false)
+}
+
+// Function react adds or removes a reaction of the authenticated user and responds with the updated post
+func (server *Server) react(w http.ResponseWriter, r *http.Request, add bool) {
+	vars := mux.Vars(r)
+	postid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+
+	if _, ok := models.Reactions[vars["reaction"]]; !ok {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("unknown_reaction", "Unknown reaction"))
+		return
+	}
+
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+
+	// Checks if the post exist
+	err = server.db(r).Model(models.Post{}).Where("id = ?", postid).Take(&models.Post{}).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, apperror.NotFound("post_not_found", "Post not found"))
+		return
+	}
+
+	reaction := models.Reaction{PostID: postid, UserID: uid, Name: vars["reaction"]}
+	if add {
+		_, err = reaction.SaveReaction(server.db(r))
+	} else {
+		_, err = reaction.DeleteReaction(server.db(r))
+	}
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	post := models.Post{}
+	postReceived, err := post.FIndPostByID(*server.db(r), postid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	posts := []models.Post{*postReceived}
+	err = models.LoadReactions(server.db(r), posts, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, dto.NewPost(posts[0], server.viewer(r)))
+}
+
+func (server *Server) AddBookmark(w http.ResponseWriter, r *http.Request) {
+	server.bookmark(w, r, true)
+}
+
+func (server *Server) RemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	server.bookmark(w, r, false)
+}
+
+// Function bookmark saves or removes a post from the bookmarks of the authenticated user
+func (server *Server) bookmark(w http.ResponseWriter, r *http.Request, add bool) {
+	vars := mux.Vars(r)
+	postid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+
+	// Checks if the post exist
+	err = server.db(r).Model(models.Post{}).Where("id = ?", postid).Take(&models.Post{}).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, apperror.NotFound("post_not_found", "Post not found"))
+		return
+	}
+
+	bookmark := models.Bookmark{UserID: uid, PostID: postid}
+	if add {
+		bookmarkSaved, err := bookmark.SaveBookmark(server.db(r))
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+		responses.JSON(w, http.StatusOK, bookmarkSaved)
+		return
+	}
+
+	_, err = bookmark.DeleteBookmark(server.db(r))
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.NoContent(w)
+}
+
+func (server *Server) GetBookmarks(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+
+	// Bookmarks are private to their owner
+	tokenID, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+	if tokenID != uint32(uid) {
+		responses.ERROR(w, http.StatusForbidden, apperror.Forbidden("not_bookmark_owner", "Bookmarks are only visible to their owner"))
+		return
+	}
+
+	page, perPage := paginate(r)
+
+	posts, total, err := models.FindUserBookmarks(server.db(r), uint32(uid), page, perPage)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	err = models.LoadReactions(server.db(r), posts, tokenID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.List(w, r, http.StatusOK, Page{Data: dto.NewPosts(posts, server.viewer(r)), Page: page, PerPage: perPage, Total: total})
+}
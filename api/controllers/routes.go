@@ -1,26 +1,153 @@
 package controllers
 
-import "github.com/AbdulrahmanDaud10/fullstack-project/api/middlewares"
+import (
+	"net/http"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/metrics"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/middlewares"
+	"github.com/gorilla/mux"
+)
 
 func (server *Server) initializeRoutes() {
 
+	maxBodyBytes := server.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+	compressionMinBytes := server.CompressionMinBytes
+	if compressionMinBytes <= 0 {
+		compressionMinBytes = DefaultCompressionMinBytes
+	}
+
+	// Every request goes through these, including the ones no route matches, which is where CORS preflights end up
+	chain := []mux.MiddlewareFunc{
+		middlewares.SetMiddlewareRequestID,
+		middlewares.SetMiddlewareIdentity,
+		setMiddlewareViewer,
+		middlewares.SetMiddlewareTracing,
+		middlewares.SetMiddlewareLogging,
+		middlewares.SetMiddlewareMetrics,
+		middlewares.SetMiddlewareRecovery,
+		middlewares.SetMiddlewareSecurityHeaders(server.Security),
+		middlewares.SetMiddlewareCORS(server.CORS),
+		middlewares.SetMiddlewareBodyLimit(maxBodyBytes),
+		middlewares.SetMiddlewareCompression(compressionMinBytes),
+		middlewares.SetMiddlewareNegotiation,
+	}
+	server.Router.Use(chain...)
+	server.Router.NotFoundHandler = middlewares.Chain(http.HandlerFunc(server.NotFound), chain...)
+	server.Router.MethodNotAllowedHandler = middlewares.Chain(http.HandlerFunc(server.MethodNotAllowed), chain...)
+
+	// Operational routes are not part of the versioned API
+	public := server.Router.NewRoute().Subrouter()
+	public.Use(middlewares.SetMiddlewareJSON)
+
 	// Home Route
-	server.Router.HandleFunc("/", middlewares.SetMiddlewareJSON(server.Home)).Methods("GET")
+	public.HandleFunc("/", server.Home).Methods("GET")
+
+	// Documentation Routes
+	public.HandleFunc("/openapi.json", server.OpenAPI).Methods("GET")
+	server.Router.HandleFunc("/docs", server.Docs).Methods("GET")
+	server.Router.HandleFunc("/docs/{asset}", server.DocsAsset).Methods("GET")
 
-	// Login Route
-	server.Router.HandleFunc("/login", middlewares.SetMiddlewareJSON(server.Login)).Methods("POST")
+	// Health Routes
+	public.HandleFunc("/healthz", server.Healthz).Methods("GET")
+	public.HandleFunc("/readyz", server.Readyz).Methods("GET")
+
+	// GraphQL Routes, mutations are only run for POST. The playground is only served in development.
+	public.HandleFunc("/graphql", server.GraphQL).Methods("GET", "POST")
+	if server.GraphQLPlayground {
+		server.Router.HandleFunc("/graphql/playground", server.GraphiQL).Methods("GET")
+	}
+
+	// Metrics Route
+	server.Router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Version 1 of the API. A later version gets its own subrouter and routes function, so it can change handlers and
+	// response shapes while v1 keeps being served next to it.
+	v1 := server.Router.PathPrefix("/v1").Subrouter()
+	server.v1Routes(v1)
+
+	// The unversioned paths are aliases of v1 kept for older clients until the sunset, every response says so
+	deprecation := server.Deprecation
+	if deprecation.Since.IsZero() {
+		deprecation.Since = LegacyDeprecatedSince
+	}
+	if deprecation.Sunset.IsZero() {
+		deprecation.Sunset = LegacySunset
+	}
+	deprecation.Successor = "/v1"
+	server.legacy = server.Router.NewRoute().Subrouter()
+	server.legacy.Use(middlewares.SetMiddlewareDeprecation(deprecation))
+	server.v1Routes(server.legacy)
+}
 
-	//Users routes
-	server.Router.HandleFunc("/users", middlewares.SetMiddlewareJSON(server.CreateUser)).Methods("POST")
-	server.Router.HandleFunc("/users", middlewares.SetMiddlewareJSON(server.GetUsers)).Methods("GET")
-	server.Router.HandleFunc("/users/{id}", middlewares.SetMiddlewareJSON(server.GetUser)).Methods("GET")
-	server.Router.HandleFunc("/users/{id}", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(server.UpdateUser))).Methods("PUT")
-	server.Router.HandleFunc("/users/{id}", middlewares.SetMiddlewareAuthentication(server.DeleteUser)).Methods("DELETE")
+// Function v1Routes registers the routes of version 1 of the API on router
+func (server *Server) v1Routes(router *mux.Router) {
+	// JSON routes anyone can call
+	public := router.NewRoute().Subrouter()
+	public.Use(middlewares.SetMiddlewareJSON)
+
+	// JSON routes that need a valid token
+	private := router.NewRoute().Subrouter()
+	private.Use(middlewares.SetMiddlewareJSON, middlewares.SetMiddlewareAuthentication)
+
+	// Login Route, it has no idempotency key since a replay would hand out a saved token
+	public.HandleFunc("/login", server.Limiter.Limit("login", server.Login)).Methods("POST")
+
+	//Users routes, POST routes that create resources accept an Idempotency-Key inside the rate limit, so rejected
+	//requests are not saved and replays still count
+	public.HandleFunc("/users", server.Limiter.Limit("register", server.Idempotency.Handle(server.CreateUser))).Methods("POST")
+	public.HandleFunc("/users", server.GetUsers).Methods("GET")
+	public.HandleFunc("/users/{id}", server.GetUser).Methods("GET")
+	private.HandleFunc("/users/{id}", server.UpdateUser).Methods("PUT")
+	private.HandleFunc("/users/{id}", server.PatchUser).Methods("PATCH")
+	// Password changes share the login limit, both let a client guess passwords
+	private.HandleFunc("/users/{id}/password", server.Limiter.Limit("login", server.ChangePassword)).Methods("PUT")
+	private.HandleFunc("/users/{id}", server.DeleteUser).Methods("DELETE")
+	private.HandleFunc("/users/{id}/follow", server.FollowUser).Methods("PUT")
+	private.HandleFunc("/users/{id}/follow", server.UnfollowUser).Methods("DELETE")
+	public.HandleFunc("/users/{id}/followers", server.GetFollowers).Methods("GET")
+	public.HandleFunc("/users/{id}/following", server.GetFollowing).Methods("GET")
+	private.HandleFunc("/users/{id}/bookmarks", server.GetBookmarks).Methods("GET")
 
 	//Posts routes
-	server.Router.HandleFunc("/posts", middlewares.SetMiddlewareJSON(server.CreatePost)).Methods("POST")
-	server.Router.HandleFunc("/posts", middlewares.SetMiddlewareJSON(server.GetPosts)).Methods("GET")
-	server.Router.HandleFunc("/posts/{id}", middlewares.SetMiddlewareJSON(server.GetPost)).Methods("GET")
-	server.Router.HandleFunc("/posts/{id}", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(server.UpdatePost))).Methods("PUT")
-	server.Router.HandleFunc("/posts/{id}", middlewares.SetMiddlewareAuthentication(server.DeletePost)).Methods("DELETE")
+	private.HandleFunc("/posts", server.Limiter.Limit("create_post", server.Idempotency.Handle(server.CreatePost))).Methods("POST")
+	public.HandleFunc("/posts", server.GetPosts).Methods("GET")
+	public.HandleFunc("/posts/{id}", server.GetPost).Methods("GET")
+	private.HandleFunc("/posts/{id}", server.UpdatePost).Methods("PUT")
+	private.HandleFunc("/posts/{id}", server.PatchPost).Methods("PATCH")
+	private.HandleFunc("/posts/{id}", server.DeletePost).Methods("DELETE")
+
+	//Feed route
+	private.HandleFunc("/feed", server.GetFeed).Methods("GET")
+
+	//Notifications routes
+	private.HandleFunc("/notifications", server.GetNotifications).Methods("GET")
+	private.HandleFunc("/notifications/read", server.MarkAllNotificationsRead).Methods("PUT")
+	private.HandleFunc("/notifications/stream", server.StreamNotifications).Methods("GET")
+	private.HandleFunc("/notifications/preferences", server.GetNotificationPreferences).Methods("GET")
+	private.HandleFunc("/notifications/preferences", server.UpdateNotificationPreferences).Methods("PUT")
+	private.HandleFunc("/notifications/{id}/read", server.MarkNotificationRead).Methods("PUT")
+
+	//Reactions and bookmarks routes
+	private.HandleFunc("/posts/{id}/reactions/{reaction}", server.AddReaction).Methods("PUT")
+	private.HandleFunc("/posts/{id}/reactions/{reaction}", server.RemoveReaction).Methods("DELETE")
+	private.HandleFunc("/posts/{id}/bookmark", server.AddBookmark).Methods("PUT")
+	private.HandleFunc("/posts/{id}/bookmark", server.RemoveBookmark).Methods("DELETE")
+
+	//Comments routes
+	private.HandleFunc("/posts/{id}/comments", server.Idempotency.Handle(server.CreateComment)).Methods("POST")
+	public.HandleFunc("/posts/{id}/comments", server.GetComments).Methods("GET")
+	private.HandleFunc("/comments/{id}", server.UpdateComment).Methods("PUT")
+	private.HandleFunc("/comments/{id}", server.DeleteComment).Methods("DELETE")
+
+	//Batch route, operations go through the routes above with the token of the batch unless they carry their own
+	public.HandleFunc("/batch", server.Idempotency.Handle(server.Batch)).Methods("POST")
+
+	//Moderation routes
+	private.HandleFunc("/admin/comments", server.GetModerationQueue).Methods("GET")
+	private.HandleFunc("/admin/comments/{id}/approve", server.ApproveComment).Methods("PUT")
+	private.HandleFunc("/admin/comments/{id}/reject", server.RejectComment).Methods("PUT")
+	private.HandleFunc("/admin/comments/{id}/spam", server.MarkCommentSpam).Methods("PUT")
 }
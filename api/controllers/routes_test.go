@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestStatusCodes(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		empty  bool // the database holds nothing instead of a user with a post and a comment
+		method string
+		path   string
+		uid    uint32 // the user of the token, none when 0
+		body   string
+		want   int
+	}{
+		{name: "unknown route", method: http.MethodGet, path: "/v1/nothing", want: http.StatusNotFound},
+		{name: "unknown method", method: http.MethodDelete, path: "/v1/posts", want: http.StatusMethodNotAllowed},
+
+		{name: "login unknown email", empty: true, method: http.MethodPost, path: "/v1/login", body: `{"email": "ada@example.com", "password": "secret"}`, want: http.StatusUnauthorized},
+		{name: "login unreadable body", method: http.MethodPost, path: "/v1/login", body: `{`, want: http.StatusBadRequest},
+
+		{name: "register", empty: true, method: http.MethodPost, path: "/v1/users", body: `{"user_name": "ada", "email": "ada@example.com", "password": "secret"}`, want: http.StatusCreated},
+		{name: "register invalid user", empty: true, method: http.MethodPost, path: "/v1/users", body: `{"user_name": "ada", "email": "ada"}`, want: http.StatusUnprocessableEntity},
+		{name: "register unreadable body", empty: true, method: http.MethodPost, path: "/v1/users", body: `{`, want: http.StatusBadRequest},
+		{name: "get user", method: http.MethodGet, path: "/v1/users/1", want: http.StatusOK},
+		{name: "get user invalid id", method: http.MethodGet, path: "/v1/users/ada", want: http.StatusBadRequest},
+		{name: "get missing user", empty: true, method: http.MethodGet, path: "/v1/users/1", want: http.StatusNotFound},
+		{name: "update user without token", method: http.MethodPut, path: "/v1/users/1", body: `{"user_name": "ada", "email": "ada@example.com", "password": "secret"}`, want: http.StatusUnauthorized},
+		{name: "update another user", method: http.MethodPut, path: "/v1/users/1", uid: 2, body: `{"user_name": "ada", "email": "ada@example.com", "password": "secret"}`, want: http.StatusForbidden},
+		{name: "delete another user", method: http.MethodDelete, path: "/v1/users/1", uid: 2, want: http.StatusForbidden},
+		{name: "delete user", method: http.MethodDelete, path: "/v1/users/1", uid: 1, want: http.StatusNoContent},
+		{name: "delete missing user", empty: true, method: http.MethodDelete, path: "/v1/users/1", uid: 1, want: http.StatusNotFound},
+
+		{name: "create post", method: http.MethodPost, path: "/v1/posts", uid: 1, body: `{"title": "Title", "content": "Content", "author_id": 1}`, want: http.StatusCreated},
+		{name: "create post as another author", method: http.MethodPost, path: "/v1/posts", uid: 2, body: `{"title": "Title", "content": "Content", "author_id": 1}`, want: http.StatusForbidden},
+		{name: "create post without token", method: http.MethodPost, path: "/v1/posts", body: `{"title": "Title", "content": "Content"}`, want: http.StatusUnauthorized},
+		{name: "create invalid post", method: http.MethodPost, path: "/v1/posts", uid: 1, body: `{"title": "Title", "author_id": 1}`, want: http.StatusUnprocessableEntity},
+		{name: "get post", method: http.MethodGet, path: "/v1/posts/1", want: http.StatusOK},
+		{name: "get post invalid id", method: http.MethodGet, path: "/v1/posts/first", want: http.StatusBadRequest},
+		{name: "get missing post", empty: true, method: http.MethodGet, path: "/v1/posts/1", want: http.StatusNotFound},
+		{name: "update another author's post", method: http.MethodPut, path: "/v1/posts/1", uid: 2, body: `{"title": "Title", "content": "Content"}`, want: http.StatusForbidden},
+		{name: "update missing post", empty: true, method: http.MethodPut, path: "/v1/posts/1", uid: 1, body: `{"title": "Title", "content": "Content"}`, want: http.StatusNotFound},
+		{name: "delete another author's post", method: http.MethodDelete, path: "/v1/posts/1", uid: 2, want: http.StatusForbidden},
+		{name: "delete post", method: http.MethodDelete, path: "/v1/posts/1", uid: 1, want: http.StatusNoContent},
+		{name: "delete missing post", empty: true, method: http.MethodDelete, path: "/v1/posts/1", uid: 1, want: http.StatusNotFound},
+
+		{name: "comment", method: http.MethodPost, path: "/v1/posts/1/comments", uid: 1, body: `{"content": "A comment"}`, want: http.StatusCreated},
+		{name: "comment on missing post", empty: true, method: http.MethodPost, path: "/v1/posts/1/comments", uid: 1, body: `{"content": "A comment"}`, want: http.StatusNotFound},
+		{name: "edit another user's comment", method: http.MethodPut, path: "/v1/comments/1", uid: 2, body: `{"content": "An edit"}`, want: http.StatusForbidden},
+		{name: "delete another user's comment", method: http.MethodDelete, path: "/v1/comments/1", uid: 2, want: http.StatusForbidden},
+		{name: "delete missing comment", empty: true, method: http.MethodDelete, path: "/v1/comments/1", uid: 1, want: http.StatusNotFound},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tables := blogTables()
+			if test.empty {
+				tables.rows = nil
+			}
+			server := testServer(t, tables.handle)
+			token := ""
+			if test.uid != 0 {
+				token = tokenFor(t, test.uid)
+			}
+
+			w := serve(server, test.method, test.path, token, test.body)
+			if w.Code != test.want {
+				t.Errorf("%s %s: got %d, want %d: %s", test.method, test.path, w.Code, test.want, w.Body)
+			}
+		})
+	}
+}
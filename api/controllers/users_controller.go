@@ -1,115 +1,295 @@
 package controllers
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strconv"
 
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/dto"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/fieldset"
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/patch"
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/formaterror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
 	"github.com/gorilla/mux"
 )
 
+// userFields is what the fields and expand parameters select from on the user routes
+var userFields = fieldset.Resource{
+	Type: dto.User{},
+	Expansions: map[string][]string{
+		"followers_count": {"followers_count"},
+		"following_count": {"following_count"},
+	},
+}
+
+// Function userLoad works out what a user query loads to show the selected fields, the id, version and update time
+// are always loaded like for posts
+func userLoad(selection fieldset.Selection) models.UserLoad {
+	return models.UserLoad{
+		Columns:      selection.Columns("", models.UserColumns, "id", "version", "updated_at"),
+		FollowCounts: selection.Expands("followers_count") || selection.Expands("following_count"),
+	}
+}
+
+// userPatch holds the fields of a user a PATCH may change, the password has its own route
+type userPatch struct {
+	UserName string `json:"user_name"`
+	Email    string `json:"email"`
+}
+
+// passwordChange is the body of a password change, the current password proves the token is used by its owner
+type passwordChange struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=6,max=72"`
+}
+
 func (server *Server) CreateUser(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
 	}
 
-	user := models.User{}
-	err = json.Unmarshal(body, &user)
+	request := dto.UserRequest{}
+	err = validation.Decode(body, &request)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
 	}
 
-	user.Prepare()
-	err = user.Validate("")
+	userCreated, err := server.registerUser(r, request)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
 	}
 
-	userCreated, err := user.SaveUser(server.DB)
+	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.RequestURI, userCreated.ID))
+	// The new user is shown to themselves, the request is not authenticated yet
+	responses.JSON(w, http.StatusCreated, dto.NewUser(*userCreated, dto.Viewer{ID: userCreated.ID}))
+}
+
+// Function registerUser checks and stores a new user. The REST and gRPC APIs both register users through it so they
+// validate them the same way.
+func (server *Server) registerUser(r *http.Request, request dto.UserRequest) (*models.User, error) {
+	err := validation.Struct(request)
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusUnprocessableEntity, formattedError)
-		return
+		return nil, err
 	}
 
-	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.RequestURI, userCreated.ID))
-	responses.JSON(w, http.StatusCreated, userCreated)
+	user := request.Model()
+	user.Prepare()
+	err = user.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	userCreated, err := user.SaveUser(server.db(r))
+	if err != nil {
+		return nil, formaterror.FormatError(err)
+	}
+	return userCreated, nil
 }
 
 func (server *Server) GetUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	uid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+	selection, err := fieldset.Parse(r.URL.Query(), userFields)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
+
 	user := models.User{}
-	userGotten, err := user.FindUserByID(server.DB, uint32(uid))
+	userGotten, err := user.FindUser(server.db(r), uint32(uid), userLoad(selection))
 	if err != nil {
-		responses.ERROR(w, http.StatusBadRequest, err)
+		responses.ERROR(w, http.StatusInternalServerError, formaterror.FormatError(err))
 		return
 	}
-	responses.JSON(w, http.StatusOK, userGotten)
+	responses.Versioned(w, r, http.StatusOK, selection.Apply(dto.NewUser(*userGotten, server.viewer(r))), userGotten.Version)
 }
 
 func (server *Server) GetUsers(w http.ResponseWriter, r *http.Request) {
+	selection, err := fieldset.Parse(r.URL.Query(), userFields)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
 	user := models.User{}
 
-	users, err := user.FindAllUsers(server.DB)
+	users, err := user.FindAllUsers(server.db(r), userLoad(selection))
 	if err != nil {
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
-	responses.JSON(w, http.StatusOK, users)
+	responses.List(w, r, http.StatusOK, selection.Apply(dto.NewUsers(*users, server.viewer(r))))
 }
 
 func (server *Server) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	uid, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		responses.ERROR(w, http.StatusBadRequest, err)
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
 		return
 	}
 	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	request := dto.UserRequest{}
+	err = validation.Decode(body, &request)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	tokenID, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+	if tokenID != uint32(uid) {
+		responses.ERROR(w, http.StatusForbidden, apperror.Forbidden("not_account_owner", "You can only update your own account"))
+		return
+	}
+	current := models.User{}
+	err = server.db(r).Model(models.User{}).Where("id = ?", uid).Take(&current).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, apperror.NotFound("user_not_found", "User not found"))
+		return
+	}
+	version, err := expectedVersion(r, current.Version)
+	if err != nil {
+		responses.ERROR(w, http.StatusPreconditionFailed, err)
+		return
+	}
+	err = validation.Struct(request)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
-	user := models.User{}
-	err = json.Unmarshal(body, &user)
+	user := request.Model()
+	user.Prepare()
+	err = user.Validate()
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
+	updateUser, err := user.UpdateUser(server.db(r), uint32(uid), version)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, formaterror.FormatError(err))
+		return
+	}
+	responses.Versioned(w, r, http.StatusOK, dto.NewUser(*updateUser, server.viewer(r)), updateUser.Version)
+}
+
+// Function PatchUser changes only the fields named in a merge patch or JSON patch, only those fields are validated
+func (server *Server) PatchUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Accept-Patch", patch.Accepted)
+
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
 	tokenID, err := auth.ExtractTokenID(r)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
 		return
 	}
 	if tokenID != uint32(uid) {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New(http.StatusText(http.StatusUnauthorized)))
+		responses.ERROR(w, http.StatusForbidden, apperror.Forbidden("not_account_owner", "You can only update your own account"))
+		return
+	}
+	current := models.User{}
+	err = server.db(r).Model(models.User{}).Where("id = ?", uid).Take(&current).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, apperror.NotFound("user_not_found", "User not found"))
+		return
+	}
+	version, err := expectedVersion(r, current.Version)
+	if err != nil {
+		responses.ERROR(w, http.StatusPreconditionFailed, err)
+		return
 	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	fields := userPatch{UserName: current.UserName, Email: current.Email}
+	changed, err := patch.Apply(r.Header.Get("Content-Type"), body, &fields)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	user := models.User{UserName: fields.UserName, Email: fields.Email}
 	user.Prepare()
-	err = user.Validate("update")
+	if len(changed) > 0 {
+		err = validation.Struct(user, changed...)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
+	updateUser, err := user.PatchUser(server.db(r), uint32(uid), changed, version)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, formaterror.FormatError(err))
+		return
+	}
+	responses.Versioned(w, r, http.StatusOK, dto.NewUser(*updateUser, server.viewer(r)), updateUser.Version)
+}
+
+// Function ChangePassword replaces the password of the authenticated user once the current one is confirmed
+func (server *Server) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
+		return
+	}
+	tokenID, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
+		return
+	}
+	if tokenID != uint32(uid) {
+		responses.ERROR(w, http.StatusForbidden, apperror.Forbidden("not_account_owner", "You can only change your own password"))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	change := passwordChange{}
+	err = validation.Decode(body, &change)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	err = validation.Struct(change)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
-	updateUser, err := user.UpdateUser(server.DB, uint32(uid))
+	user := models.User{}
+	err = user.ChangePassword(server.db(r), uint32(uid), change.CurrentPassword, change.NewPassword)
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		responses.ERROR(w, http.StatusInternalServerError, formaterror.FormatError(err))
 		return
 	}
-	responses.JSON(w, http.StatusOK, updateUser)
+	responses.NoContent(w)
 }
 
 func (server *Server) DeleteUser(w http.ResponseWriter, r *http.Request) {
@@ -119,23 +299,23 @@ func (server *Server) DeleteUser(w http.ResponseWriter, r *http.Request) {
 
 	uid, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		responses.ERROR(w, http.StatusBadRequest, err)
+		responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_id", "The id in the path is not valid"))
 		return
 	}
 	tokenID, err := auth.ExtractTokenID(r)
 	if err != nil {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
 		return
 	}
-	if tokenID != 0 && tokenID != uint32(uid) {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New(http.StatusText(http.StatusUnauthorized)))
+	if tokenID != uint32(uid) {
+		responses.ERROR(w, http.StatusForbidden, apperror.Forbidden("not_account_owner", "You can only delete your own account"))
 		return
 	}
-	_, err = user.DeleteUser(server.DB, uint32(uid))
+	_, err = user.DeleteUser(server.db(r), uint32(uid))
 	if err != nil {
-		responses.ERROR(w, http.StatusInternalServerError, err)
+		responses.ERROR(w, http.StatusInternalServerError, formaterror.FormatError(err))
 		return
 	}
 	w.Header().Set("Entity", fmt.Sprintf("%d", uid))
-	responses.JSON(w, http.StatusNoContent, "")
+	responses.NoContent(w)
 }
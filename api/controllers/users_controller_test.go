@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDeleteUserDeletesWhatBelongsToThem(t *testing.T) {
+	tables := blogTables()
+	server := testServer(t, tables.handle)
+
+	w := serve(server, http.MethodDelete, "/v1/users/1", tokenFor(t, 1), "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("got %d, want 204: %s", w.Code, w.Body)
+	}
+
+	for _, sql := range []string{"WITH RECURSIVE tree", `DELETE FROM "posts"`, `DELETE FROM "reactions"`, `DELETE FROM "bookmarks"`, `DELETE FROM "follows"`, `DELETE FROM "notifications"`, `DELETE FROM "notification_preferences"`} {
+		if !tables.ran(sql) {
+			t.Errorf("the user was deleted without running %s", sql)
+		}
+	}
+	n := len(tables.statements)
+	if last := tables.statements[n-2]; !strings.HasPrefix(last, `DELETE FROM "users"`) || tables.statements[n-1] != "COMMIT" {
+		t.Errorf("the user must be deleted after what references them, the last statements were %s and %s", last, tables.statements[n-1])
+	}
+}
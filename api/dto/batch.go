@@ -0,0 +1,34 @@
+package dto
+
+import "encoding/json"
+
+// BatchRequest is the body of a batch, its operations run in order. Atomic batches run them on a single transaction
+// that is rolled back at the first operation that fails, the operations after it are not run.
+type BatchRequest struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations" validate:"required"`
+}
+
+// BatchOperation is a request of a batch. It is sent with the token of the batch unless its headers hold another
+// Authorization, and with a JSON Content-Type unless they hold another one.
+type BatchOperation struct {
+	Method  string            `json:"method" validate:"required,oneof=GET|POST|PUT|PATCH|DELETE"`
+	Path    string            `json:"path" validate:"required"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// BatchResponse holds a result per operation in the order of the request. Committed is false when an atomic batch was
+// rolled back, the results of the operations before the failed one then show changes that were undone.
+type BatchResponse struct {
+	Atomic    bool          `json:"atomic"`
+	Committed bool          `json:"committed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// BatchResult is the response to an operation, bodies that are not JSON are given as strings
+type BatchResult struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
@@ -0,0 +1,57 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+)
+
+// Comment is a comment as responses show it, with its replies mapped the same way
+type Comment struct {
+	ID        uint64     `json:"id"`
+	Content   string     `json:"content"`
+	PostID    uint64     `json:"post_id"`
+	Author    User       `json:"author"`
+	AuthorID  uint32     `json:"author_id"`
+	ParentID  *uint64    `json:"parent_id"`
+	RootID    uint64     `json:"root_id"`
+	Depth     int        `json:"depth"`
+	Status    string     `json:"status"`
+	SpamScore float64    `json:"spam_score"`
+	Replies   []*Comment `json:"replies"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func NewComment(comment models.Comment, viewer Viewer) Comment {
+	response := Comment{
+		ID:        comment.ID,
+		Content:   comment.Content,
+		PostID:    comment.PostID,
+		Author:    NewUser(comment.Author, viewer),
+		AuthorID:  comment.AuthorID,
+		ParentID:  comment.ParentID,
+		RootID:    comment.RootID,
+		Depth:     comment.Depth,
+		Status:    comment.Status,
+		SpamScore: comment.SpamScore,
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.UpdatedAt,
+	}
+	if comment.Replies != nil {
+		response.Replies = make([]*Comment, len(comment.Replies))
+		for i, reply := range comment.Replies {
+			mapped := NewComment(*reply, viewer)
+			response.Replies[i] = &mapped
+		}
+	}
+	return response
+}
+
+func NewComments(comments []*models.Comment, viewer Viewer) []Comment {
+	responses := make([]Comment, len(comments))
+	for i, comment := range comments {
+		responses[i] = NewComment(*comment, viewer)
+	}
+	return responses
+}
@@ -0,0 +1,42 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+)
+
+// Notification is a notification as responses and the notification stream show it, the actor is always shown publicly
+type Notification struct {
+	ID        uint64    `json:"id"`
+	UserID    uint32    `json:"user_id"`
+	Type      string    `json:"type"`
+	Actor     User      `json:"actor"`
+	ActorID   uint32    `json:"actor_id"`
+	PostID    *uint64   `json:"post_id"`
+	CommentID *uint64   `json:"comment_id"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewNotification(notification models.Notification) Notification {
+	return Notification{
+		ID:        notification.ID,
+		UserID:    notification.UserID,
+		Type:      notification.Type,
+		Actor:     NewUser(notification.Actor, Viewer{}),
+		ActorID:   notification.ActorID,
+		PostID:    notification.PostID,
+		CommentID: notification.CommentID,
+		Read:      notification.Read,
+		CreatedAt: notification.CreatedAt,
+	}
+}
+
+func NewNotifications(notifications []models.Notification) []Notification {
+	responses := make([]Notification, len(notifications))
+	for i := range notifications {
+		responses[i] = NewNotification(notifications[i])
+	}
+	return responses
+}
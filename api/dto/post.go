@@ -0,0 +1,58 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+)
+
+// PostRequest is the body that creates or replaces a post
+type PostRequest struct {
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	AuthorID uint32 `json:"author_id"`
+}
+
+// Function Model maps the request onto a new post
+func (request PostRequest) Model() models.Post {
+	return models.Post{Title: request.Title, Content: request.Content, AuthorID: request.AuthorID}
+}
+
+// Post is a post as responses show it, its author in the view the viewer has of them
+type Post struct {
+	ID              uint64           `json:"id"`
+	Title           string           `json:"title"`
+	Content         string           `json:"content"`
+	Author          User             `json:"author"`
+	AuthorID        uint32           `json:"author_id"`
+	CommentCount    int64            `json:"comment_count"`
+	Reactions       map[string]int64 `json:"reactions"`
+	ViewerReactions []string         `json:"viewer_reactions"`
+	Version         uint64           `json:"version"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+}
+
+func NewPost(post models.Post, viewer Viewer) Post {
+	return Post{
+		ID:              post.ID,
+		Title:           post.Title,
+		Content:         post.Content,
+		Author:          NewUser(post.Author, viewer),
+		AuthorID:        post.AuthorID,
+		CommentCount:    post.CommentCount,
+		Reactions:       post.Reactions,
+		ViewerReactions: post.ViewerReactions,
+		Version:         post.Version,
+		CreatedAt:       post.CreatedAt,
+		UpdatedAt:       post.UpdatedAt,
+	}
+}
+
+func NewPosts(posts []models.Post, viewer Viewer) []Post {
+	responses := make([]Post, len(posts))
+	for i := range posts {
+		responses[i] = NewPost(posts[i], viewer)
+	}
+	return responses
+}
@@ -0,0 +1,97 @@
+// Package dto holds the bodies the API reads and writes. Models are never written to clients directly, every response
+// type is filled by an explicit mapping, so a column added to a model stays private until someone maps it.
+package dto
+
+import (
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+)
+
+// View decides which fields of a user a response shows
+type View int
+
+const (
+	// Public is what anyone sees of a user, a profile without contact details
+	Public View = iota
+	// Self is what users see of themselves, including their email and role
+	Self
+	// Admin is what administrators see of every user, the same fields as Self
+	Admin
+)
+
+// Viewer is who a response is written for, the zero Viewer is an anonymous client
+type Viewer struct {
+	ID    uint32
+	Admin bool
+}
+
+// Function ViewOf returns the view the viewer gets of the user with the given id
+func (viewer Viewer) ViewOf(uid uint32) View {
+	switch {
+	case viewer.Admin:
+		return Admin
+	case viewer.ID != 0 && viewer.ID == uid:
+		return Self
+	default:
+		return Public
+	}
+}
+
+// UserRequest is the body that registers or replaces a user. Only the password is checked here, the user model
+// checks the other fields once they are cleaned up.
+type UserRequest struct {
+	UserName string `json:"user_name"`
+	Email    string `json:"email"`
+	Password string `json:"password" validate:"required,min=6,max=72"`
+}
+
+// Function Model maps the request onto a new user
+func (request UserRequest) Model() models.User {
+	return models.User{UserName: request.UserName, Email: request.Email, Password: request.Password}
+}
+
+// Credentials is the body of a login
+type Credentials struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// User is a user as responses show it. It has no password field, Email and Role are only filled in the Self and Admin views.
+type User struct {
+	ID             uint32    `json:"id"`
+	UserName       string    `json:"user_name"`
+	Email          string    `json:"email,omitempty"`
+	Role           string    `json:"role,omitempty"`
+	FollowersCount int64     `json:"followers_count"`
+	FollowingCount int64     `json:"following_count"`
+	Version        uint64    `json:"version"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Function NewUser maps a user to the view the viewer has of it
+func NewUser(user models.User, viewer Viewer) User {
+	response := User{
+		ID:             user.ID,
+		UserName:       user.UserName,
+		FollowersCount: user.FollowersCount,
+		FollowingCount: user.FollowingCount,
+		Version:        user.Version,
+		CreatedAt:      user.CreatedAt,
+		UpdatedAt:      user.UpdatedAt,
+	}
+	if viewer.ViewOf(user.ID) != Public {
+		response.Email = user.Email
+		response.Role = user.Role
+	}
+	return response
+}
+
+func NewUsers(users []models.User, viewer Viewer) []User {
+	responses := make([]User, len(users))
+	for i := range users {
+		responses[i] = NewUser(users[i], viewer)
+	}
+	return responses
+}
@@ -0,0 +1,53 @@
+package dto
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+)
+
+const passwordHash = "$2a$10$abcdefghijklmnopqrstuvhashedpasswordhashedpasswordhash"
+
+// viewers sees a user anonymously, as themselves, as another user and as an administrator
+var viewers = []Viewer{{}, {ID: 1}, {ID: 2}, {ID: 2, Admin: true}}
+
+func TestResponsesHaveNoPassword(t *testing.T) {
+	author := models.User{ID: 1, UserName: "ada", Email: "ada@example.com", Password: passwordHash, Role: models.RoleUser}
+	reply := &models.Comment{ID: 2, Author: author, AuthorID: 1}
+	comment := models.Comment{ID: 1, Author: author, AuthorID: 1, Replies: []*models.Comment{reply}}
+	post := models.Post{ID: 1, Title: "Title", Author: author, AuthorID: 1}
+
+	for _, viewer := range viewers {
+		for name, response := range map[string]interface{}{
+			"user":     NewUser(author, viewer),
+			"users":    NewUsers([]models.User{author}, viewer),
+			"post":     NewPost(post, viewer),
+			"posts":    NewPosts([]models.Post{post}, viewer),
+			"comment":  NewComment(comment, viewer),
+			"comments": NewComments([]*models.Comment{&comment}, viewer),
+		} {
+			body, err := json.Marshal(response)
+			if err != nil {
+				t.Fatalf("cannot marshal the %s: %v", name, err)
+			}
+			if strings.Contains(strings.ToLower(string(body)), "password") || strings.Contains(string(body), passwordHash) {
+				t.Errorf("the %s seen by %+v shows a password: %s", name, viewer, body)
+			}
+		}
+	}
+}
+
+func TestUserViews(t *testing.T) {
+	user := models.User{ID: 1, UserName: "ada", Email: "ada@example.com", Role: models.RoleUser}
+	for _, test := range []struct {
+		viewer  Viewer
+		private bool
+	}{{Viewer{}, false}, {Viewer{ID: 1}, true}, {Viewer{ID: 2}, false}, {Viewer{ID: 2, Admin: true}, true}} {
+		response := NewUser(user, test.viewer)
+		if (response.Email != "") != test.private || (response.Role != "") != test.private {
+			t.Errorf("%+v sees email %q and role %q", test.viewer, response.Email, response.Role)
+		}
+	}
+}
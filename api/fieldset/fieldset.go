@@ -0,0 +1,256 @@
+// Package fieldset reads the fields and expand query parameters of read routes. fields trims a response to the listed
+// fields, nested fields are named with dots as in author.user_name, and expand lists the related resources a response
+// embeds. Both are checked against the response type so a typo is reported instead of silently returning nothing.
+package fieldset
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+)
+
+// Resource describes a response type clients can select from
+type Resource struct {
+	// Type is a value of the response type, its JSON field names are the fields clients can list
+	Type interface{}
+	// Expansions maps each related resource a response can embed to the fields it fills. Responses embed all of them
+	// unless the client narrows them with expand or fields.
+	Expansions map[string][]string
+}
+
+// tree holds selected field names, a nil subtree keeps the whole field
+type tree map[string]tree
+
+// Selection is what a client asked to see of a resource
+type Selection struct {
+	fields   tree // nil keeps every field
+	expanded map[string]bool
+}
+
+// Function Parse reads the selection of a request. Without expand every related resource is embedded, with it only
+// the listed ones are, and naming a field of a related resource in fields embeds it too.
+func Parse(query url.Values, resource Resource) (Selection, error) {
+	typ := reflect.TypeOf(resource.Type)
+	expansionOf := map[string]string{}
+	for name, fields := range resource.Expansions {
+		for _, field := range fields {
+			expansionOf[field] = name
+		}
+	}
+
+	selection := Selection{expanded: map[string]bool{}}
+	for _, name := range split(query["expand"]) {
+		if _, ok := resource.Expansions[name]; !ok {
+			return Selection{}, apperror.BadRequest("unknown_expansion", fmt.Sprintf("%s cannot be expanded, expand accepts %s", name, strings.Join(expansions(resource), ", ")))
+		}
+		selection.expanded[name] = true
+	}
+
+	if paths := split(query["fields"]); len(paths) > 0 {
+		selection.fields = tree{}
+		for _, path := range paths {
+			err := selection.fields.add(path, typ)
+			if err != nil {
+				return Selection{}, err
+			}
+			if name, ok := expansionOf[strings.SplitN(path, ".", 2)[0]]; ok {
+				selection.expanded[name] = true
+			}
+		}
+		// Expanded resources are shown even when fields does not list them
+		for name := range selection.expanded {
+			for _, field := range resource.Expansions[name] {
+				if _, ok := selection.fields[field]; !ok {
+					selection.fields[field] = nil
+				}
+			}
+		}
+		return selection, nil
+	}
+
+	if _, ok := query["expand"]; !ok {
+		for name := range resource.Expansions {
+			selection.expanded[name] = true
+		}
+		return selection, nil
+	}
+
+	// The related resources the client did not list are left out of the response
+	selection.fields = tree{}
+	for name := range fieldsOf(typ) {
+		if expansion, ok := expansionOf[name]; ok && !selection.expanded[expansion] {
+			continue
+		}
+		selection.fields[name] = nil
+	}
+	return selection, nil
+}
+
+// Function Expands reports whether responses embed the related resource
+func (selection Selection) Expands(name string) bool {
+	return selection.expanded[name]
+}
+
+// Function Columns lists the columns to load for the fields selected under prefix, "" for the resource itself and the
+// name of a related resource for the one it embeds. Fields are named as their columns, so the selected fields that are
+// available are kept and the required columns are always added. available is returned as it is when every field is selected.
+func (selection Selection) Columns(prefix string, available []string, required ...string) []string {
+	fields := selection.fields
+	if prefix != "" {
+		for _, name := range strings.Split(prefix, ".") {
+			if fields == nil {
+				break
+			}
+			fields = fields[name]
+		}
+	}
+	if fields == nil {
+		return available
+	}
+
+	columns := append([]string{}, required...)
+	for _, column := range available {
+		if _, ok := fields[column]; ok && !slices.Contains(columns, column) {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// Function Apply trims a response, or a slice of them, to the selected fields. The value is returned unchanged when every
+// field is selected, otherwise objects become maps keyed by their JSON field names.
+func (selection Selection) Apply(value interface{}) interface{} {
+	if selection.fields == nil {
+		return value
+	}
+	return project(reflect.ValueOf(value), selection.fields)
+}
+
+// Function add selects the field at a dotted path after checking that typ has it
+func (fields tree) add(path string, typ reflect.Type) error {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		field, ok := fieldsOf(typ)[name]
+		if !ok {
+			return apperror.BadRequest("unknown_field", fmt.Sprintf("%s is not a field of this resource", path))
+		}
+
+		subfields, selected := fields[name]
+		if selected && subfields == nil {
+			return nil // the whole field is already selected
+		}
+		if i == len(names)-1 {
+			fields[name] = nil
+			return nil
+		}
+		if !selected {
+			subfields = tree{}
+			fields[name] = subfields
+		}
+		fields, typ = subfields, field
+	}
+	return nil
+}
+
+// Function project copies the selected fields of a value into maps
+func project(value reflect.Value, fields tree) interface{} {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Slice:
+		if value.IsNil() {
+			return nil
+		}
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = project(value.Index(i), fields)
+		}
+		return items
+	case reflect.Struct:
+		object := make(map[string]interface{}, len(fields))
+		typ := value.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			name, omitempty := jsonName(typ.Field(i))
+			subfields, ok := fields[name]
+			if name == "" || !ok {
+				continue
+			}
+			field := value.Field(i)
+			if omitempty && field.IsZero() {
+				continue
+			}
+			if subfields == nil {
+				object[name] = field.Interface()
+			} else {
+				object[name] = project(field, subfields)
+			}
+		}
+		return object
+	}
+	return value.Interface()
+}
+
+// Function fieldsOf maps the JSON field names of a struct type, or of the elements of a slice of them, to their types
+func fieldsOf(typ reflect.Type) map[string]reflect.Type {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	fields := map[string]reflect.Type{}
+	if typ.Kind() != reflect.Struct {
+		return fields
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		if name, _ := jsonName(typ.Field(i)); name != "" {
+			fields[name] = typ.Field(i).Type
+		}
+	}
+	return fields
+}
+
+// Function jsonName returns the name encoding/json writes a field under, "" for fields it skips
+func jsonName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, strings.Contains(options, "omitempty")
+}
+
+// Function split reads a comma separated list that may be given over several query parameters
+func split(values []string) []string {
+	items := []string{}
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+func expansions(resource Resource) []string {
+	names := make([]string, 0, len(resource.Expansions))
+	for name := range resource.Expansions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
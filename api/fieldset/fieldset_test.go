@@ -0,0 +1,163 @@
+package fieldset
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+)
+
+type author struct {
+	ID       uint32 `json:"id"`
+	UserName string `json:"user_name"`
+	Email    string `json:"email,omitempty"`
+}
+
+type post struct {
+	ID           uint64  `json:"id"`
+	Title        string  `json:"title"`
+	Content      string  `json:"content"`
+	Author       *author `json:"author,omitempty"`
+	AuthorID     uint32  `json:"author_id"`
+	CommentCount int64   `json:"comment_count"`
+	Version      uint64  `json:"version"`
+}
+
+var posts = Resource{
+	Type: post{},
+	Expansions: map[string][]string{
+		"author":        {"author"},
+		"comment_count": {"comment_count"},
+	},
+}
+
+func samplePost() post {
+	return post{
+		ID:           1,
+		Title:        "Title",
+		Content:      "Content",
+		Author:       &author{ID: 1, UserName: "ada", Email: "ada@example.com"},
+		AuthorID:     1,
+		CommentCount: 2,
+		Version:      3,
+	}
+}
+
+// Function parse reads the selection of a raw query string and fails the test when it is refused
+func parse(t *testing.T, query string) Selection {
+	t.Helper()
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatalf("cannot parse %q: %v", query, err)
+	}
+	selection, err := Parse(values, posts)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", query, err)
+	}
+	return selection
+}
+
+func TestFieldsKeepOnlyTheListedKeys(t *testing.T) {
+	selection := parse(t, "fields=title,author.user_name")
+
+	got := selection.Apply(samplePost())
+	want := map[string]interface{}{
+		"title":  "Title",
+		"author": map[string]interface{}{"user_name": "ada"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	if !selection.Expands("author") || selection.Expands("comment_count") {
+		t.Errorf("naming author.user_name must embed the author and only it: author %v, comment_count %v", selection.Expands("author"), selection.Expands("comment_count"))
+	}
+	if got := selection.Columns("", []string{"id", "title", "content", "author_id"}, "id", "author_id"); !reflect.DeepEqual(got, []string{"id", "author_id", "title"}) {
+		t.Errorf("post columns: got %v", got)
+	}
+	if got := selection.Columns("author", []string{"id", "user_name", "email"}, "id"); !reflect.DeepEqual(got, []string{"id", "user_name"}) {
+		t.Errorf("author columns: got %v", got)
+	}
+
+	// Slices are trimmed item by item
+	items := selection.Apply([]post{samplePost(), samplePost()}).([]interface{})
+	if len(items) != 2 || !reflect.DeepEqual(items[1], want) {
+		t.Errorf("got %#v", items)
+	}
+}
+
+func TestWithoutFieldsEverythingIsKept(t *testing.T) {
+	selection := parse(t, "")
+
+	value := samplePost()
+	if got := selection.Apply(value); !reflect.DeepEqual(got, value) {
+		t.Errorf("the response was changed: %#v", got)
+	}
+	if !selection.Expands("author") || !selection.Expands("comment_count") {
+		t.Error("related resources are embedded unless expand narrows them")
+	}
+	available := []string{"id", "title", "content"}
+	if got := selection.Columns("", available, "id"); !reflect.DeepEqual(got, available) {
+		t.Errorf("got columns %v, want all of them", got)
+	}
+}
+
+func TestExpandLeavesOutTheOtherRelatedResources(t *testing.T) {
+	selection := parse(t, "expand=author")
+
+	if !selection.Expands("author") || selection.Expands("comment_count") {
+		t.Fatalf("author %v, comment_count %v", selection.Expands("author"), selection.Expands("comment_count"))
+	}
+	got := selection.Apply(samplePost()).(map[string]interface{})
+	if _, ok := got["comment_count"]; ok {
+		t.Errorf("comment_count was not expanded but is in the response: %v", got)
+	}
+	for _, key := range []string{"id", "title", "content", "author", "author_id", "version"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("%s is missing from the response: %v", key, got)
+		}
+	}
+
+	// An empty expand embeds nothing, fields still embeds what it names
+	if selection := parse(t, "expand="); selection.Expands("author") || selection.Expands("comment_count") {
+		t.Error("an empty expand embedded a related resource")
+	}
+	selection = parse(t, "expand=author&fields=title")
+	got = selection.Apply(samplePost()).(map[string]interface{})
+	if len(got) != 2 || got["author"] == nil || got["title"] != "Title" {
+		t.Errorf("expand with fields: got %v, want the title and the whole author", got)
+	}
+}
+
+func TestOmittedFieldsStayOmitted(t *testing.T) {
+	selection := parse(t, "fields=title,author.email")
+	value := samplePost()
+	value.Author.Email = ""
+
+	got := selection.Apply(value).(map[string]interface{})
+	if author := got["author"].(map[string]interface{}); len(author) != 0 {
+		t.Errorf("an empty email was written although it is omitempty: %v", author)
+	}
+}
+
+func TestUnknownNamesAreRefused(t *testing.T) {
+	for _, test := range []struct {
+		query string
+		code  string
+	}{
+		{query: "fields=titel", code: "unknown_field"},
+		{query: "fields=author.nickname", code: "unknown_field"},
+		{query: "fields=title.length", code: "unknown_field"},
+		{query: "expand=comments", code: "unknown_expansion"},
+	} {
+		values, _ := url.ParseQuery(test.query)
+		_, err := Parse(values, posts)
+
+		var appErr *apperror.Error
+		if !errors.As(err, &appErr) || appErr.Status != 400 || appErr.Code != test.code {
+			t.Errorf("%s: got %v, want a 400 %s", test.query, err, test.code)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package graph
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/graphql-go/graphql"
+)
+
+// DefaultFirst and MaxFirst size the pages of connections the way per_page sizes the pages of the REST API
+const (
+	DefaultFirst = 10
+	MaxFirst     = 100
+)
+
+// Page is a page of a connection: the first nodes after the node with the id After, from the start when After is 0.
+// Connections page on ids, so a page does not shift when nodes are added before it.
+type Page struct {
+	First int
+	After uint64
+}
+
+// ConnectionArgs are the arguments of a connection field
+var ConnectionArgs = graphql.FieldConfigArgument{
+	"first": &graphql.ArgumentConfig{Type: graphql.Int, Description: fmt.Sprintf("How many nodes to return, %d by default and %d at most", DefaultFirst, MaxFirst)},
+	"after": &graphql.ArgumentConfig{Type: graphql.String, Description: "Return the nodes after this cursor, the endCursor of the previous page"},
+}
+
+// Function PageOf reads the page a connection field asks for
+func PageOf(args map[string]interface{}) (Page, error) {
+	page := Page{First: DefaultFirst}
+	if first, ok := args["first"].(int); ok {
+		if first < 0 || first > MaxFirst {
+			return Page{}, apperror.BadRequest("invalid_page", fmt.Sprintf("first must be between 0 and %d", MaxFirst))
+		}
+		page.First = first
+	}
+	if after, ok := args["after"].(string); ok && after != "" {
+		id, err := decodeCursor(after)
+		if err != nil {
+			return Page{}, err
+		}
+		page.After = id
+	}
+	return page, nil
+}
+
+// Connection is a page of a list as Relay clients expect it
+type Connection struct {
+	Edges    []Edge
+	PageInfo PageInfo
+	// Count asks for the size of the whole list when a client wants totalCount. It returns a thunk, so the counts of
+	// the connections of several parents can be batched by a loader.
+	Count func() func() (interface{}, error)
+}
+
+type Edge struct {
+	Cursor string
+	Node   interface{}
+}
+
+type PageInfo struct {
+	HasNextPage     bool
+	HasPreviousPage bool
+	StartCursor     *string
+	EndCursor       *string
+}
+
+// Function NewConnection builds a connection from the nodes found for a page, nodes must be fetched in id order with
+// one more node than the page holds so the connection knows whether a next page exists
+func NewConnection[T any](nodes []T, page Page, id func(T) uint64, count func() func() (interface{}, error)) Connection {
+	connection := Connection{Edges: []Edge{}, Count: count}
+	connection.PageInfo.HasPreviousPage = page.After != 0
+	if len(nodes) > page.First {
+		nodes = nodes[:page.First]
+		connection.PageInfo.HasNextPage = true
+	}
+	for _, node := range nodes {
+		connection.Edges = append(connection.Edges, Edge{Cursor: encodeCursor(id(node)), Node: node})
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.StartCursor = &connection.Edges[0].Cursor
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+	return connection
+}
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"hasNextPage":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"hasPreviousPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"startCursor":     &graphql.Field{Type: graphql.String},
+		"endCursor":       &graphql.Field{Type: graphql.String},
+	},
+})
+
+// Function ConnectionType builds the connection and edge types of a node type, e.g. PostConnection and PostEdge for Post
+func ConnectionType(node *graphql.Object) *graphql.Object {
+	edge := graphql.NewObject(graphql.ObjectConfig{
+		Name: node.Name() + "Edge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"node":   &graphql.Field{Type: graphql.NewNonNull(node)},
+		},
+	})
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: node.Name() + "Connection",
+		Fields: graphql.Fields{
+			"edges":    &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edge)))},
+			"pageInfo": &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+			"totalCount": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(Connection).Count(), nil
+				},
+			},
+		},
+	})
+}
+
+// Cursors are opaque to clients, they wrap the id of a node so they can change without breaking anyone
+const cursorPrefix = "cursor:"
+
+func encodeCursor(id uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatUint(id, 10)))
+}
+
+func decodeCursor(cursor string) (uint64, error) {
+	invalid := apperror.BadRequest("invalid_cursor", "The cursor is not one this API handed out")
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, invalid
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(string(raw), cursorPrefix), 10, 64)
+	if err != nil {
+		return 0, invalid
+	}
+	return id, nil
+}
@@ -0,0 +1,152 @@
+// Package graph serves the GraphQL API. It holds what does not depend on the schema: reading requests, limiting how
+// deep and costly a query may be, batching the loads of a query, paging lists as Relay connections and turning domain
+// errors into GraphQL errors. The schema itself lives with the controllers, next to the REST handlers it shares code with.
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/formaterror"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Request is a GraphQL request, POST requests send it as a JSON body and GET requests in the query string
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Function ReadRequest reads the GraphQL request sent with a POST body or a GET query string
+func ReadRequest(r *http.Request) (Request, error) {
+	request := Request{}
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		request.Query = query.Get("query")
+		request.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			err := json.Unmarshal([]byte(variables), &request.Variables)
+			if err != nil {
+				return Request{}, apperror.BadRequest("invalid_graphql_request", "The variables are not a JSON object")
+			}
+		}
+	} else {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return Request{}, err
+		}
+		err = json.Unmarshal(body, &request)
+		if err != nil {
+			return Request{}, apperror.BadRequest("invalid_graphql_request", "The body is not a GraphQL request")
+		}
+	}
+
+	if request.Query == "" {
+		return Request{}, apperror.BadRequest("invalid_graphql_request", "The request has no query")
+	}
+	return request, nil
+}
+
+// Function Execute runs a request against the schema once it is valid and within the limits. Mutations are refused
+// when readOnly is set, which GET requests are, so following a link never changes anything.
+func Execute(ctx context.Context, schema graphql.Schema, request Request, limits Limits, readOnly bool) *graphql.Result {
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(request.Query), Name: "GraphQL request"})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	validation := graphql.ValidateDocument(&schema, document, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	operation := operationOf(document, request.OperationName)
+	if operation == nil {
+		return failed("unknown_operation", "The request does not have the operation to run")
+	}
+	if readOnly && operation.Operation != ast.OperationTypeQuery {
+		return failed("mutation_not_allowed", "Mutations must be sent with POST")
+	}
+
+	err = limits.Check(schema, document, operation, request.Variables)
+	if err != nil {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{formatted(err)}}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        schema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       ctx,
+	})
+}
+
+// Function operationOf finds the operation a request runs, the only one of the document when the request names none
+func operationOf(document *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if found != nil {
+				return nil
+			}
+			found = operation
+		} else if operation.Name != nil && operation.Name.Value == name {
+			return operation
+		}
+	}
+	return found
+}
+
+func failed(code, message string) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{formatted(apperror.BadRequest(code, message))}}
+}
+
+// Function formatted turns an error raised outside of a resolver into a GraphQL error with its extensions
+func formatted(err error) gqlerrors.FormattedError {
+	return gqlerrors.FormatError(gqlerrors.NewLocatedError(Error(err), nil))
+}
+
+// extendedError carries a domain error into the extensions of a GraphQL error
+type extendedError struct {
+	err *apperror.Error
+}
+
+func (err extendedError) Error() string {
+	return err.err.Detail
+}
+
+func (err extendedError) Unwrap() error {
+	return err.err
+}
+
+func (err extendedError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": err.err.Code, "status": err.err.Status}
+	if len(err.err.Fields) > 0 {
+		extensions["fields"] = err.err.Fields
+	}
+	return extensions
+}
+
+// Function Error turns an error a resolver ran into into a GraphQL error. Its code, HTTP status and invalid fields are
+// the ones the REST API answers with, and unknown errors are hidden behind a generic internal error the same way.
+func Error(err error) error {
+	var appErr *apperror.Error
+	if !errors.As(formaterror.FormatError(err), &appErr) {
+		appErr = apperror.Internal("internal_error", "Something went wrong")
+	}
+	return extendedError{appErr}
+}
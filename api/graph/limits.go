@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Limits bound the queries clients may run, so one request cannot make the server walk the whole database. A zero
+// limit is not enforced.
+type Limits struct {
+	// MaxDepth is how deeply fields may be nested, the fields of an operation are at depth 1
+	MaxDepth int
+	// MaxComplexity is how many fields a query may resolve at most. Every field counts once and the fields under a
+	// connection count once for every node of the page it asks for.
+	MaxComplexity int
+}
+
+// DefaultLimits leave room for a page of posts with their authors and comment threads, but not for a page of comments
+// under every post of a page of users
+var DefaultLimits = Limits{MaxDepth: 12, MaxComplexity: 5000}
+
+// Function Check measures the operation a request runs against the limits. Introspection fields are not counted,
+// tools ask for the whole schema through deeply nested type references.
+func (limits Limits) Check(schema graphql.Schema, document *ast.Document, operation *ast.OperationDefinition, variables map[string]interface{}) error {
+	var root *graphql.Object
+	switch operation.Operation {
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	case ast.OperationTypeSubscription:
+		root = schema.SubscriptionType()
+	default:
+		root = schema.QueryType()
+	}
+
+	measure := measure{schema: schema, fragments: map[string]*ast.FragmentDefinition{}, variables: variables, visiting: map[string]bool{}}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			measure.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	depth, complexity := measure.selections(operation.SelectionSet, root)
+	if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+		return apperror.BadRequest("query_too_deep", fmt.Sprintf("The query nests fields %d levels deep, at most %d are allowed", depth, limits.MaxDepth))
+	}
+	if limits.MaxComplexity > 0 && complexity > limits.MaxComplexity {
+		return apperror.BadRequest("query_too_complex", fmt.Sprintf("The query may resolve %d fields, at most %d are allowed, ask for smaller pages", complexity, limits.MaxComplexity))
+	}
+	return nil
+}
+
+// measure walks the selections of an operation with the types they select from
+type measure struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	visiting  map[string]bool // fragments being measured, a fragment spreading itself is not followed again
+}
+
+// Function selections returns how deep a selection set goes and how many fields it resolves
+func (measure *measure) selections(set *ast.SelectionSet, parent graphql.Type) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	depth, complexity := 0, 0
+	add := func(d, c int) {
+		depth = max(depth, d)
+		complexity += c
+	}
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			var child graphql.Type
+			multiplier := 1
+			if object, ok := parent.(*graphql.Object); ok {
+				if field, ok := object.Fields()[selection.Name.Value]; ok {
+					child, _ = graphql.GetNamed(field.Type).(graphql.Type)
+					multiplier = measure.pageSize(selection, field)
+				}
+			}
+			d, c := measure.selections(selection.SelectionSet, child)
+			add(d+1, 1+multiplier*c)
+		case *ast.InlineFragment:
+			typ := parent
+			if selection.TypeCondition != nil {
+				typ = measure.schema.Type(selection.TypeCondition.Name.Value)
+			}
+			add(measure.selections(selection.SelectionSet, typ))
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := measure.fragments[name]
+			if !ok || measure.visiting[name] {
+				continue
+			}
+			measure.visiting[name] = true
+			add(measure.selections(fragment.SelectionSet, measure.schema.Type(fragment.TypeCondition.Name.Value)))
+			measure.visiting[name] = false
+		}
+	}
+	return depth, complexity
+}
+
+// Function pageSize returns how many nodes a connection field asks for, 1 for fields that are not connections
+func (measure *measure) pageSize(selection *ast.Field, field *graphql.FieldDefinition) int {
+	isConnection := false
+	for _, argument := range field.Args {
+		isConnection = isConnection || argument.Name() == "first"
+	}
+	if !isConnection {
+		return 1
+	}
+
+	first := DefaultFirst
+	for _, argument := range selection.Arguments {
+		if argument.Name.Value != "first" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil {
+				first = n
+			}
+		case *ast.Variable:
+			if n, ok := measure.variables[value.Name.Value].(float64); ok {
+				first = int(n)
+			}
+		}
+	}
+	return min(max(first, 1), MaxFirst)
+}
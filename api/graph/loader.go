@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"context"
+	"sync"
+)
+
+// Loader batches the loads of one kind made while a query resolves, e.g. the authors of a page of posts. Resolvers ask
+// for a key and get a thunk back. The executor calls thunks level by level once every field of a level has resolved,
+// so the first thunk called fetches all the keys asked for so far in one query and the others find their value ready.
+// A loader lives for a single request, values are never shared between clients.
+type Loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending []K
+	queued  map[K]bool
+	values  map[K]V
+	errs    map[K]error
+}
+
+// Function NewLoader returns a loader that fetches keys with fetch, keys missing from the map it returns load the zero V
+func NewLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{fetch: fetch, queued: map[K]bool{}, values: map[K]V{}, errs: map[K]error{}}
+}
+
+// Function Load asks for the value of a key, it is fetched with the other pending keys when the thunk is first called
+func (loader *Loader[K, V]) Load(ctx context.Context, key K) func() (V, error) {
+	loader.mu.Lock()
+	_, loaded := loader.values[key]
+	_, failed := loader.errs[key]
+	if !loaded && !failed && !loader.queued[key] {
+		loader.pending = append(loader.pending, key)
+		loader.queued[key] = true
+	}
+	loader.mu.Unlock()
+
+	return func() (V, error) {
+		loader.mu.Lock()
+		defer loader.mu.Unlock()
+
+		if len(loader.pending) > 0 {
+			loader.flush(ctx)
+		}
+		return loader.values[key], loader.errs[key]
+	}
+}
+
+// Function flush fetches the pending keys, a failed fetch fails every key of the batch. The lock is held.
+func (loader *Loader[K, V]) flush(ctx context.Context) {
+	keys := loader.pending
+	loader.pending = nil
+	clear(loader.queued)
+
+	values, err := loader.fetch(ctx, keys)
+	for _, key := range keys {
+		if err != nil {
+			loader.errs[key] = err
+			continue
+		}
+		loader.values[key] = values[key]
+	}
+}
+
+// PageKey is the key of a page of a list that belongs to a parent, e.g. the comment threads of a post
+type PageKey[K comparable] struct {
+	Parent K
+	Page   Page
+}
+
+// Function ByPage adapts a fetch of the same page of several parents into the fetch of a loader keyed by PageKey. Keys
+// are grouped by page, so a query asking every post for its first ten comments fetches them in a single batch.
+func ByPage[K comparable, V any](fetch func(ctx context.Context, parents []K, page Page) (map[K]V, error)) func(ctx context.Context, keys []PageKey[K]) (map[PageKey[K]]V, error) {
+	return func(ctx context.Context, keys []PageKey[K]) (map[PageKey[K]]V, error) {
+		parents := map[Page][]K{}
+		for _, key := range keys {
+			parents[key.Page] = append(parents[key.Page], key.Parent)
+		}
+
+		values := map[PageKey[K]]V{}
+		for page, ids := range parents {
+			found, err := fetch(ctx, ids, page)
+			if err != nil {
+				return nil, err
+			}
+			for id, value := range found {
+				values[PageKey[K]{Parent: id, Page: page}] = value
+			}
+		}
+		return values, nil
+	}
+}
@@ -0,0 +1,147 @@
+// Package health runs the readiness checks of the API and reports each of them separately.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of a report and of each of its checks
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Timeout bounds how long a single check may run
+var Timeout = 2 * time.Second
+
+// ErrShuttingDown is reported once Shutdown has been called
+var ErrShuttingDown = errors.New("the server is shutting down")
+
+// Check returns an error when the dependency it looks at is not usable, it must give up once ctx is done
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one check
+type Result struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Report is the outcome of every check, its status is ok only when all checks are
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker holds the named checks readiness depends on
+type Checker struct {
+	mu           sync.RWMutex
+	names        []string
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{checks: map[string]Check{}}
+}
+
+// Function Register adds a check, registering a name again replaces its check
+func (checker *Checker) Register(name string, check Check) {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+
+	if _, ok := checker.checks[name]; !ok {
+		checker.names = append(checker.names, name)
+	}
+	checker.checks[name] = check
+}
+
+// Function Shutdown makes every following report fail, so load balancers stop sending traffic before the server stops
+func (checker *Checker) Shutdown() {
+	checker.shuttingDown.Store(true)
+}
+
+// Function Run runs the checks concurrently, each with its own timeout, and collects their results
+func (checker *Checker) Run(ctx context.Context) Report {
+	checker.mu.RLock()
+	names := append([]string(nil), checker.names...)
+	checks := make(map[string]Check, len(checker.checks))
+	for name, check := range checker.checks {
+		checks[name] = check
+	}
+	checker.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(names)+1)}
+	if checker.shuttingDown.Load() {
+		report.Status = StatusFail
+		report.Checks["shutdown"] = Result{Status: StatusFail, Error: ErrShuttingDown.Error()}
+	}
+
+	results := make([]Result, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}(i, checks[name])
+	}
+	wg.Wait()
+
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Status: StatusOK, DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Function Cached wraps a check that is too costly to run on every probe, its result is reused for ttl
+func Cached(check Check, ttl time.Duration) Check {
+	var mu sync.Mutex
+	var checkedAt time.Time
+	var last error
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !checkedAt.IsZero() && time.Since(checkedAt) < ttl {
+			return last
+		}
+		last = check(ctx)
+		if !errors.Is(last, context.DeadlineExceeded) && !errors.Is(last, context.Canceled) {
+			checkedAt = time.Now()
+		}
+		return last
+	}
+}
@@ -0,0 +1,205 @@
+// Package idempotency makes retried POST requests safe. A client sends an Idempotency-Key header with a request,
+// the first response to that key is saved for the owner of the request and replayed to every retry, so a request
+// that timed out on a flaky network can be sent again without creating the resource twice.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/logging"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/ratelimit"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+)
+
+// Header is the request header carrying the key, ReplayedHeader marks responses that were replayed
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// MaxKeyLength bounds the keys clients may send, a UUID is well below it
+const MaxKeyLength = 255
+
+// DefaultTTL is how long a response is kept when no TTL is configured
+const DefaultTTL = 24 * time.Hour
+
+var (
+	// ErrInProgress is returned by a store while another request holds the key
+	ErrInProgress = errors.New("idempotency key in use")
+	// ErrMismatch is returned by a store when the key was used for a different request
+	ErrMismatch = errors.New("idempotency key reused")
+)
+
+// Response is what is saved of the first response to a key and replayed to retries
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Store keeps the keys and their responses, so instances sharing a store also share keys
+type Store interface {
+	// Begin claims key for a request with the given fingerprint. It returns the saved response when the key already
+	// completed, ErrInProgress while another request holds the key and ErrMismatch when it was used for another request.
+	Begin(ctx context.Context, key, fingerprint string, now time.Time, ttl time.Duration) (*Response, error)
+	// Finish saves the response of a claimed key until the TTL expires
+	Finish(ctx context.Context, key string, response Response, now time.Time, ttl time.Duration) error
+	// Release forgets a claimed key whose request failed, so the client can retry it
+	Release(ctx context.Context, key string) error
+}
+
+// Guard applies idempotency keys to handlers, keys are scoped to the user behind the token or to the client address
+type Guard struct {
+	Store   Store
+	TTL     time.Duration
+	Proxies ratelimit.TrustedProxies
+}
+
+func NewGuard(store Store, ttl time.Duration, proxies ratelimit.TrustedProxies) *Guard {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Guard{Store: store, TTL: ttl, Proxies: proxies}
+}
+
+// Function Handle wraps a handler so requests with an Idempotency-Key run it at most once per key. Requests without
+// the header run as usual, and the handler is returned as it is when the guard is nil.
+func (guard *Guard) Handle(next http.HandlerFunc) http.HandlerFunc {
+	if guard == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > MaxKeyLength {
+			responses.ERROR(w, http.StatusBadRequest, apperror.BadRequest("invalid_idempotency_key", "The Idempotency-Key header must be at most "+strconv.Itoa(MaxKeyLength)+" characters"))
+			return
+		}
+
+		// The body is read here to fingerprint the request, the handler reads it again from memory
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := context.WithoutCancel(r.Context())
+		id := guard.owner(r) + " " + key
+		saved, err := guard.Store.Begin(ctx, id, fingerprint(r, body), time.Now(), guard.TTL)
+		switch {
+		case errors.Is(err, ErrInProgress):
+			w.Header().Set("Retry-After", "1")
+			responses.ERROR(w, http.StatusConflict, apperror.Conflict("idempotency_key_in_use", "A request with this Idempotency-Key is still being processed"))
+			return
+		case errors.Is(err, ErrMismatch):
+			responses.ERROR(w, http.StatusUnprocessableEntity, apperror.New(http.StatusUnprocessableEntity, "idempotency_key_reused", "This Idempotency-Key was already used for a different request"))
+			return
+		case err != nil:
+			// A broken store must not take the API down with it, the request runs without the guarantee
+			logging.FromContext(r.Context()).WarnContext(r.Context(), "idempotency store failed", "error", err.Error())
+			next(w, r)
+			return
+		case saved != nil:
+			replay(w, saved)
+			return
+		}
+
+		recorder := &recorder{ResponseWriter: w, before: w.Header().Clone()}
+		// Deferred so a panicking handler still releases its key
+		defer func() {
+			// Server errors are not saved, the failure may be transient and the client should be able to retry
+			if recorder.status == 0 || recorder.status >= http.StatusInternalServerError {
+				err = guard.Store.Release(ctx, id)
+			} else {
+				err = guard.Store.Finish(ctx, id, recorder.response(), time.Now(), guard.TTL)
+			}
+			if err != nil {
+				logging.FromContext(ctx).WarnContext(ctx, "idempotency store failed", "error", err.Error())
+			}
+		}()
+		next(recorder, r)
+	}
+}
+
+// Function owner scopes keys to the user behind a valid token, or to the client address for anonymous requests
+func (guard *Guard) owner(r *http.Request) string {
+	if auth.ExtractToken(r) != "" {
+		if uid, err := auth.ExtractTokenID(r); err == nil && uid != 0 {
+			return "user:" + strconv.FormatUint(uint64(uid), 10)
+		}
+	}
+	return "ip:" + guard.Proxies.ClientIP(r)
+}
+
+// Function fingerprint identifies the request a key was used for, a retry must send the same method, URL and body
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, saved *Response) {
+	header := w.Header()
+	for name, values := range saved.Header {
+		header[name] = values
+	}
+	header.Set(ReplayedHeader, "true")
+	w.WriteHeader(saved.Status)
+	w.Write(saved.Body)
+}
+
+// recorder copies the response the handler writes. Only the headers the handler set are kept, the ones middlewares
+// set before it, like the request id, belong to each request and are set again on replays.
+type recorder struct {
+	http.ResponseWriter
+	before http.Header
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		rec.header = http.Header{}
+		for name, values := range rec.ResponseWriter.Header() {
+			if !slices.Equal(rec.before[name], values) {
+				rec.header[name] = append([]string(nil), values...)
+			}
+		}
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *recorder) response() Response {
+	return Response{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()}
+}
@@ -0,0 +1,153 @@
+package idempotency
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Function creating returns a handler that creates a resource per call, numbered from 1, and counts its calls
+func creating(calls *atomic.Int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		n := calls.Add(1)
+		w.Header().Set("Location", "/v1/posts/"+strconv.FormatInt(n, 10))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}
+}
+
+// Function post sends a POST from the address with the key and body to handler, the request id stands for the headers
+// middlewares set before the handler runs
+func post(handler http.HandlerFunc, addr, key, body, requestID string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(body))
+	r.RemoteAddr = addr + ":4242"
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Request-ID", requestID)
+	handler(w, r)
+	return w
+}
+
+func TestRetriesGetTheFirstResponse(t *testing.T) {
+	calls := &atomic.Int64{}
+	handler := NewGuard(NewMemoryStore(), time.Hour, nil).Handle(creating(calls))
+
+	first := post(handler, "192.0.2.1", "key-1", `{"title": "Title"}`, "first")
+	if first.Code != http.StatusCreated || first.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("first request: got %d %v", first.Code, first.Header())
+	}
+
+	retry := post(handler, "192.0.2.1", "key-1", `{"title": "Title"}`, "retry")
+	if calls.Load() != 1 {
+		t.Fatalf("the handler ran %d times, want once", calls.Load())
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() || retry.Header().Get("Location") != first.Header().Get("Location") {
+		t.Errorf("the retry got %d %v %s, want the first response", retry.Code, retry.Header(), retry.Body)
+	}
+	if retry.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("the retry has no %s header: %v", ReplayedHeader, retry.Header())
+	}
+	if retry.Header().Get("X-Request-ID") != "retry" {
+		t.Errorf("the retry got the request id of the first request: %v", retry.Header())
+	}
+
+	// Keys belong to whoever sent them, and requests without one are never replayed
+	if w := post(handler, "192.0.2.2", "key-1", `{"title": "Title"}`, "other"); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("another client got a replay: %d %v", w.Code, w.Header())
+	}
+	post(handler, "192.0.2.1", "", `{"title": "Title"}`, "unkeyed")
+	post(handler, "192.0.2.1", "", `{"title": "Title"}`, "unkeyed")
+	if calls.Load() != 4 {
+		t.Errorf("the handler ran %d times, want 4", calls.Load())
+	}
+}
+
+func TestKeyReusedForAnotherRequest(t *testing.T) {
+	calls := &atomic.Int64{}
+	handler := NewGuard(NewMemoryStore(), time.Hour, nil).Handle(creating(calls))
+
+	post(handler, "192.0.2.1", "key-1", `{"title": "Title"}`, "first")
+	w := post(handler, "192.0.2.1", "key-1", `{"title": "Another title"}`, "second")
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"idempotency_key_reused"`) {
+		t.Fatalf("got %d %s, want 422 idempotency_key_reused", w.Code, w.Body)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("the handler ran %d times, want once", calls.Load())
+	}
+}
+
+func TestKeyInProgress(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := NewGuard(NewMemoryStore(), time.Hour, nil).Handle(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		post(handler, "192.0.2.1", "key-1", `{}`, "first")
+		close(done)
+	}()
+	<-started
+
+	w := post(handler, "192.0.2.1", "key-1", `{}`, "second")
+	close(release)
+	<-done
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") != "1" || !strings.Contains(w.Body.String(), `"idempotency_key_in_use"`) {
+		t.Fatalf("got %d %v %s, want 409 idempotency_key_in_use with Retry-After", w.Code, w.Header(), w.Body)
+	}
+}
+
+func TestServerErrorsAreNotReplayed(t *testing.T) {
+	calls := &atomic.Int64{}
+	handler := NewGuard(NewMemoryStore(), time.Hour, nil).Handle(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	if w := post(handler, "192.0.2.1", "key-1", `{}`, "first"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("first request: got %d", w.Code)
+	}
+	if w := post(handler, "192.0.2.1", "key-1", `{}`, "retry"); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("the retry of a failed request got %d %v, want it to run again", w.Code, w.Header())
+	}
+}
+
+func TestKeyTooLong(t *testing.T) {
+	handler := NewGuard(NewMemoryStore(), time.Hour, nil).Handle(creating(&atomic.Int64{}))
+	w := post(handler, "192.0.2.1", strings.Repeat("k", MaxKeyLength+1), `{}`, "first")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d, want 400", w.Code)
+	}
+}
+
+func TestExpiredKeysRunAgain(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	if _, err := store.Begin(context.Background(), "key-1", "print", now, time.Minute); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	store.Finish(context.Background(), "key-1", Response{Status: http.StatusCreated}, now, time.Minute)
+
+	saved, err := store.Begin(context.Background(), "key-1", "print", now.Add(30*time.Second), time.Minute)
+	if err != nil || saved == nil || saved.Status != http.StatusCreated {
+		t.Fatalf("before the TTL: got %v, %v, want the saved response", saved, err)
+	}
+	saved, err = store.Begin(context.Background(), "key-1", "print", now.Add(2*time.Minute), time.Minute)
+	if err != nil || saved != nil {
+		t.Fatalf("after the TTL: got %v, %v, want the key claimed again", saved, err)
+	}
+}
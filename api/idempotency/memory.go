@@ -0,0 +1,76 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the keys of a single instance in memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	sweptAt time.Time
+}
+
+type entry struct {
+	fingerprint string
+	response    *Response // nil while the request is in progress
+	expires     time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]*entry{}}
+}
+
+func (store *MemoryStore) Begin(ctx context.Context, key, fingerprint string, now time.Time, ttl time.Duration) (*Response, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.sweep(now)
+
+	e, ok := store.entries[key]
+	if !ok || !now.Before(e.expires) {
+		store.entries[key] = &entry{fingerprint: fingerprint, expires: now.Add(ttl)}
+		return nil, nil
+	}
+	if e.fingerprint != fingerprint {
+		return nil, ErrMismatch
+	}
+	if e.response == nil {
+		return nil, ErrInProgress
+	}
+	return e.response, nil
+}
+
+func (store *MemoryStore) Finish(ctx context.Context, key string, response Response, now time.Time, ttl time.Duration) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if e, ok := store.entries[key]; ok {
+		e.response = &response
+		e.expires = now.Add(ttl)
+	}
+	return nil
+}
+
+func (store *MemoryStore) Release(ctx context.Context, key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.entries, key)
+	return nil
+}
+
+// Function sweep forgets the expired keys, at most once a minute, so memory stays bounded by the keys of the last TTL
+func (store *MemoryStore) sweep(now time.Time) {
+	if now.Sub(store.sweptAt) < time.Minute {
+		return
+	}
+	store.sweptAt = now
+	for key, e := range store.entries {
+		if !now.Before(e.expires) {
+			delete(store.entries, key)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// SlowQuery is how long a query may take before it is logged as slow at the warn level
+var SlowQuery = 200 * time.Millisecond
+
+// GormLogger sends the SQL gorm runs to slog. Unless Params is set the statements are logged with their placeholders,
+// so values such as password hashes and emails never reach the logs.
+type GormLogger struct {
+	Level  logger.LogLevel
+	Params bool
+}
+
+// Function NewGormLogger returns a gorm logger for the given level: silent, error, warn or info, anything else means warn
+func NewGormLogger(level string, params bool) *GormLogger {
+	return &GormLogger{Level: ParseSQLLevel(level), Params: params}
+}
+
+// Function ParseSQLLevel maps a level name to its gorm log level, defaulting to warn which logs failed and slow queries
+func ParseSQLLevel(level string) logger.LogLevel {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "silent", "off":
+		return logger.Silent
+	case "error":
+		return logger.Error
+	case "info", "debug":
+		return logger.Info
+	default:
+		return logger.Warn
+	}
+}
+
+func (l *GormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	copied := *l
+	copied.Level = level
+	return &copied
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.Level >= logger.Info {
+		FromContext(ctx).InfoContext(ctx, msg, "args", args)
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.Level >= logger.Warn {
+		FromContext(ctx).WarnContext(ctx, msg, "args", args)
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.Level >= logger.Error {
+		FromContext(ctx).ErrorContext(ctx, msg, "args", args)
+	}
+}
+
+// Function Trace logs a finished query: failures at the error level, slow queries at warn and every other query at info
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.Level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	log := FromContext(ctx)
+	switch {
+	case err != nil && l.Level >= logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		log.ErrorContext(ctx, "sql query failed", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds(), "error", err.Error())
+	case elapsed > SlowQuery && l.Level >= logger.Warn:
+		sql, rows := fc()
+		log.WarnContext(ctx, "slow sql query", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	case l.Level >= logger.Info:
+		sql, rows := fc()
+		log.InfoContext(ctx, "sql query", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	}
+}
+
+// Function ParamsFilter drops the query parameters before gorm renders the statement for Trace, unless Params is set
+func (l *GormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.Params {
+		return sql, params
+	}
+	return sql, nil
+}
+
+var _ gorm.ParamsFilter = (*GormLogger)(nil)
@@ -0,0 +1,75 @@
+// Package metrics exposes the Prometheus metrics of the API. Every label takes its values from a bounded set,
+// requests are labelled with route templates such as /posts/{id} and never with raw paths.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "blog"
+
+// Registry holds every metric of the API together with the Go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	// Requests counts the served requests by route template, method and status
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route template, method and status.",
+	}, []string{"route", "method", "status"})
+
+	// RequestDuration observes how long requests take by route template, method and status
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by route template, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// InFlight is the number of requests being served
+	InFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests currently being served.",
+	})
+
+	// QueryDuration observes how long gorm queries take by operation and table
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database query latency, by gorm operation and table.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	// Logins counts login attempts by result: success, failure for wrong credentials or error when the login could not be checked
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts, by result.",
+	}, []string{"result"})
+
+	// DeprecatedRequests counts the requests to deprecated routes by route template and method
+	DeprecatedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deprecated_requests_total",
+		Help:      "HTTP requests to deprecated routes, by route template and method.",
+	}, []string{"route", "method"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Requests, RequestDuration, InFlight, QueryDuration, Logins, DeprecatedRequests,
+	)
+}
+
+// Function Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,190 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/mux"
+)
+
+// Content encodings the server can compress with, in order of preference
+var compressionEncodings = []string{"br", "gzip", "identity"}
+
+var (
+	gzipWriters   = sync.Pool{New: func() any { w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression); return w }}
+	brotliWriters = sync.Pool{New: func() any { return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression) }}
+)
+
+// SetMiddlewareNegotiation encodes responses in the format the Accept header asks for, see responses.Negotiate.
+// It must run last, right before the handlers, so responses.JSON finds the negotiated writer.
+func SetMiddlewareNegotiation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(responses.WithEncoder(w, r), r)
+	})
+}
+
+// SetMiddlewareCompression compresses responses with brotli or gzip, whichever Accept-Encoding prefers.
+// Bodies smaller than minSize are sent as they are, compressing them costs more than it saves.
+func SetMiddlewareCompression(minSize int) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			// Clients that do not send Accept-Encoding are not assumed to decompress anything
+			accept := r.Header.Get("Accept-Encoding")
+			encoding := responses.Preferred(accept, compressionEncodings)
+			if accept == "" || encoding == "" || encoding == "identity" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Not deferred: when the handler panics nothing buffered must go out, the recovery middleware answers instead
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize}
+			next.ServeHTTP(cw, r)
+			cw.Close()
+		})
+	}
+}
+
+// compressWriter holds the body back until it knows whether it is worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status     int
+	buf        []byte
+	started    bool
+	compressor io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.started || cw.status != 0 {
+		return
+	}
+	cw.status = status
+	// Responses without a body are sent right away
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.started {
+		if cw.compressor != nil {
+			return cw.compressor.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Function start sends the headers and the buffered body, through a compressor when compress is set and the content allows it
+func (cw *compressWriter) start(compress bool) error {
+	cw.started = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	header := cw.Header()
+	if compress && header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		// The compressed bytes are not the ones a strong tag was computed for, the representation is only equivalent
+		if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			header.Set("ETag", "W/"+etag)
+		}
+		switch cw.encoding {
+		case "br":
+			writer := brotliWriters.Get().(*brotli.Writer)
+			writer.Reset(cw.ResponseWriter)
+			cw.compressor = writer
+		case "gzip":
+			writer := gzipWriters.Get().(*gzip.Writer)
+			writer.Reset(cw.ResponseWriter)
+			cw.compressor = writer
+		}
+	} else if len(cw.buf) > 0 && header.Get("Content-Length") == "" {
+		header.Set("Content-Length", strconv.Itoa(len(cw.buf)))
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	buf := cw.buf
+	cw.buf = nil
+	if cw.compressor != nil {
+		_, err := cw.compressor.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// Function Flush gives up on compression for bodies that are streamed before reaching the threshold, such as server sent events
+func (cw *compressWriter) Flush() {
+	if !cw.started {
+		cw.start(false)
+	}
+	if flusher, ok := cw.compressor.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (cw *compressWriter) Close() {
+	if !cw.started {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			return
+		}
+		cw.start(false)
+	}
+	if cw.compressor == nil {
+		return
+	}
+	cw.compressor.Close()
+	switch writer := cw.compressor.(type) {
+	case *brotli.Writer:
+		brotliWriters.Put(writer)
+	case *gzip.Writer:
+		gzipWriters.Put(writer)
+	}
+	cw.compressor = nil
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Function compressible reports whether a content type is worth compressing, streams and already compressed media are not
+func compressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.HasPrefix(contentType, "text/event-stream"):
+		return false
+	case strings.HasPrefix(contentType, "text/"),
+		strings.Contains(contentType, "json"),
+		strings.Contains(contentType, "xml"),
+		strings.Contains(contentType, "javascript"),
+		strings.Contains(contentType, "msgpack"),
+		strings.Contains(contentType, "cbor"):
+		return true
+	default:
+		return false
+	}
+}
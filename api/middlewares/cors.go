@@ -0,0 +1,92 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CORSConfig says which browser origins may call the API
+type CORSConfig struct {
+	// AllowedOrigins are full origins such as https://app.example.com, or * for any origin
+	AllowedOrigins []string
+	// AllowCredentials lets browsers send cookies and Authorization headers, it cannot be combined with *
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+var (
+	corsMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	corsHeaders = []string{"Authorization", "Content-Type", "Accept", "If-Match", "If-None-Match", "If-Modified-Since", "Idempotency-Key", "X-Request-ID", "traceparent", "tracestate"}
+	// Headers scripts on another origin are allowed to read from responses
+	corsExposed = []string{"Location", "ETag", "Accept-Patch", "Idempotent-Replayed", "Deprecation", "Sunset", "Link", "Retry-After", "RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "X-Request-ID", "X-Trace-ID"}
+)
+
+// Function Validate rejects configurations browsers would refuse
+func (config CORSConfig) Validate() error {
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" && config.AllowCredentials {
+			return errors.New("cors: credentials cannot be allowed for every origin")
+		}
+	}
+	return nil
+}
+
+func (config CORSConfig) allows(origin string) bool {
+	for _, allowed := range config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMiddlewareCORS adds the CORS headers for allowed origins and answers preflight requests itself.
+// Preflights never match a route, so the middleware must also wrap the router's not found and method not allowed handlers.
+func SetMiddlewareCORS(config CORSConfig) mux.MiddlewareFunc {
+	wildcard := false
+	for _, origin := range config.AllowedOrigins {
+		wildcard = wildcard || origin == "*"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			header := w.Header()
+			header.Add("Vary", "Origin")
+			if origin == "" || !config.allows(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if wildcard {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+			if config.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				header.Add("Vary", "Access-Control-Request-Method")
+				header.Add("Vary", "Access-Control-Request-Headers")
+				header.Set("Access-Control-Allow-Methods", strings.Join(corsMethods, ", "))
+				header.Set("Access-Control-Allow-Headers", strings.Join(corsHeaders, ", "))
+				if config.MaxAge > 0 {
+					header.Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			header.Set("Access-Control-Expose-Headers", strings.Join(corsExposed, ", "))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
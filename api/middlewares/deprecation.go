@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/metrics"
+	"github.com/gorilla/mux"
+)
+
+// DeprecationConfig says when a group of routes was deprecated and when it goes away
+type DeprecationConfig struct {
+	Since  time.Time
+	Sunset time.Time // zero when no removal date is set
+	// Successor is the prefix of the routes replacing these, it is linked as the successor version of each request
+	Successor string
+}
+
+// Function SetMiddlewareDeprecation announces on every response that the route is deprecated, with the Deprecation
+// (RFC 9745) and Sunset (RFC 8594) headers, and counts the requests so the sunset can wait for clients to move
+func SetMiddlewareDeprecation(config DeprecationConfig) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			header.Set("Deprecation", "@"+strconv.FormatInt(config.Since.Unix(), 10))
+			if !config.Sunset.IsZero() {
+				header.Set("Sunset", config.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if config.Successor != "" {
+				header.Add("Link", "<"+config.Successor+r.URL.EscapedPath()+`>; rel="successor-version"`)
+			}
+
+			metrics.DeprecatedRequests.WithLabelValues(RouteTemplate(r), metricMethod(r.Method)).Inc()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
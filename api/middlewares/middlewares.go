@@ -1,27 +1,256 @@
 package middlewares
 
 import (
-	"errors"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"net/http"
+	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/logging"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/metrics"
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/tracing"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func SetMiddlewareJSON(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		next(w, r)
+// Function Chain wraps a handler in middlewares, the first one runs first, like mux.Router.Use does for matched routes
+func Chain(handler http.Handler, middlewares ...mux.MiddlewareFunc) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
 	}
+	return handler
+}
+
+func SetMiddlewareJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		next.ServeHTTP(w, r)
+	})
 }
 
-func SetMiddlewareAuthentication(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+// SetMiddlewareIdentity gives every request a place to keep who sends it. The first middleware or handler that needs
+// the user behind the token verifies it, SetMiddlewareAuthentication for private routes, and the others reuse the outcome.
+func SetMiddlewareIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, auth.WithIdentity(r))
+	})
+}
+
+func SetMiddlewareAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		err := auth.TokenValid(r)
 		if err != nil {
-			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			responses.ERROR(w, http.StatusUnauthorized, apperror.Unauthorized("invalid_token", "Unauthorized"))
 			return
 		}
-		next(w, r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SetMiddlewareBodyLimit refuses request bodies larger than limit bytes, reading past it fails with *http.MaxBytesError
+func SetMiddlewareBodyLimit(limit int64) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				responses.ERROR(w, http.StatusRequestEntityTooLarge, &http.MaxBytesError{Limit: limit})
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type contextKey string
+
+const requestIDKey contextKey = "request_id"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// SetMiddlewareRequestID tags every request with an id, reusing the X-Request-ID sent by the client or a proxy when it looks sane.
+// The id is echoed in the response headers, which is also where error responses pick it up from.
+func SetMiddlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		ctx := context.WithValue(r.Context(), requestIDKey, id)
+		ctx = logging.WithContext(ctx, logging.FromContext(ctx).With("request_id", id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Function RequestID returns the id SetMiddlewareRequestID gave to the request
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// SetMiddlewareTracing starts a server span for every request, continuing the trace of an incoming traceparent header.
+// The trace id is echoed in the X-Trace-ID response header, which error responses pick up, and added to the request logger.
+func SetMiddlewareTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := RouteTemplate(r)
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+				semconv.ClientAddress(r.RemoteAddr),
+			),
+		)
+		defer span.End()
+
+		if id := RequestID(r); id != "" {
+			span.SetAttributes(attribute.String("http.request.header.x-request-id", id))
+		}
+		if span.SpanContext().HasTraceID() {
+			traceID := span.SpanContext().TraceID().String()
+			w.Header().Set("X-Trace-ID", traceID)
+			ctx = logging.WithContext(ctx, logging.FromContext(ctx).With("trace_id", traceID))
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// statusRecorder remembers the status and the number of bytes a handler wrote
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Function Flush keeps streaming handlers such as server sent events working behind the recorder
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// SetMiddlewareLogging writes one log line per request once it has been served, it must run after SetMiddlewareRequestID
+// and SetMiddlewareIdentity. The user is logged when the request was authenticated, the token is not verified again.
+// The route is logged as its template, e.g. /posts/{id}, so lines for the same endpoint can be grouped.
+func SetMiddlewareLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		attrs := []any{
+			"method", r.Method,
+			"route", RouteTemplate(r),
+			"status", rec.status,
+			"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", rec.bytes,
+		}
+		if uid, ok := auth.VerifiedUserID(r); ok {
+			attrs = append(attrs, "user_id", uid)
+		}
+
+		log := logging.FromContext(r.Context())
+		switch {
+		case rec.status >= http.StatusInternalServerError:
+			log.ErrorContext(r.Context(), "request", attrs...)
+		case rec.status >= http.StatusBadRequest:
+			log.WarnContext(r.Context(), "request", attrs...)
+		default:
+			log.InfoContext(r.Context(), "request", attrs...)
+		}
+	})
+}
+
+// SetMiddlewareMetrics counts and times every request by route template, method and status and tracks the requests in flight
+func SetMiddlewareMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics.InFlight.Inc()
+		defer metrics.InFlight.Dec()
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		labels := []string{RouteTemplate(r), metricMethod(r.Method), strconv.Itoa(rec.status)}
+		metrics.Requests.WithLabelValues(labels...).Inc()
+		metrics.RequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Function metricMethod keeps the method label bounded by folding unknown methods into OTHER
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+// Function RouteTemplate returns the template of the route that matched the request, or "unmatched"
+func RouteTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
 	}
+	return "unmatched"
 }
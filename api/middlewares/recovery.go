@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/logging"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+)
+
+// SetMiddlewareRecovery turns a panicking handler into a 500 problem response and logs the panic with its stack.
+// When the handler had already started its response nothing more can be sent, the connection is left to close.
+func SetMiddlewareRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			logging.FromContext(r.Context()).ErrorContext(r.Context(), "handler panicked", "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+			if rec.status == 0 {
+				responses.ERROR(w, http.StatusInternalServerError, apperror.Internal("internal_error", "Something went wrong"))
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -1,24 +1,35 @@
 package models
 
 import (
-	"errors"
 	"html"
-	"log"
 	"strings"
 	"time"
 
-	"github.com/badoux/checkmail"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Roles a user can hold, moderators and admins are allowed to manage content they do not own
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
 )
 
 type User struct {
-	ID        uint32    `gorm:"primary_key;auto_increment" json:"id"`
-	UserName  string    `gorm:"size:255;not null;unique" json:"user_name"`
-	Email     string    `gorm:"size:100;not null;unique" json:"email"`
-	Password  string    `gorm:"size:100;not null;" json:"password"`
-	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	ID             uint32    `gorm:"primary_key;auto_increment" json:"id"`
+	UserName       string    `gorm:"size:255;not null;unique" json:"user_name" validate:"required,max=255"`
+	Email          string    `gorm:"size:100;not null;unique" json:"email" validate:"required,email,max=100"`
+	Password       string    `gorm:"size:100;not null;" json:"-"` // the plain password until HashPassword replaces it with its bcrypt hash, never written to clients
+	Role           string    `gorm:"size:20;not null;default:user" json:"role"`
+	FollowersCount int64     `gorm:"-" json:"followers_count"`
+	FollowingCount int64     `gorm:"-" json:"following_count"`
+	Version        uint64    `gorm:"not null;default:1" json:"version"`
+	CreatedAt      time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 }
 
 // Hash function takes in password as a string and GenerateFromPassword returns the bcrypt hash of the password
@@ -31,8 +42,9 @@ func VerifyPassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
-// Function BeforeSave checks that the hashed password exists first before saving it, returns errror if its does not exist
-func (user *User) BeforeSave() error {
+// Function HashPassword replaces the password of the user with its bcrypt hash. gorm does not call it, whatever stores a
+// new password calls it first.
+func (user *User) HashPassword() error {
 	hashedPassword, err := Hash(user.Password)
 	if err != nil {
 		return err
@@ -47,65 +59,31 @@ func (user *User) Prepare() {
 	user.ID = 0
 	user.UserName = html.EscapeString(strings.TrimSpace(user.UserName))
 	user.Email = html.EscapeString(strings.TrimSpace(user.Email))
+	user.Role = RoleUser
+	user.Version = 1
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 }
 
-// Function Validate converts all the strings to lowercases and then verifies is not empty and prompts messages if the Users details are empty or in an invalid format
-func (user *User) Validate(action string) error {
-	switch strings.ToLower(action) {
-
-	case "update":
-		if user.UserName == "" {
-			return errors.New("Username Required")
-		}
-		if user.Password == "" {
-			return errors.New("Password Required")
-		}
-		if user.Email == "" {
-			return errors.New("Email Required")
-		}
-		if err := checkmail.ValidateFormat(user.Email); err != nil {
-			return errors.New("Invalid Email")
-		}
-
-		return nil
-
-	case "login":
-		if user.Password == "" {
-			return errors.New("Password Required")
-		}
-		if user.Email == "" {
-			return errors.New("Email Required")
-		}
-		if err := checkmail.ValidateFormat(user.Email); err != nil {
-			return errors.New("Invalid EmailS")
-		}
-
-		return nil
-
-	default:
-		if user.UserName == "" {
-			return errors.New("Required Nickname")
-		}
-		if user.Password == "" {
-			return errors.New("Required Password")
-		}
-		if user.Email == "" {
-			return errors.New("Required Email")
-		}
-		if err := checkmail.ValidateFormat(user.Email); err != nil {
-			return errors.New("Invalid Email")
-		}
-		return nil
-	}
+// Function IsModerator reports whether the user may moderate content created by other users
+func (user *User) IsModerator() bool {
+	return user.Role == RoleModerator || user.Role == RoleAdmin
+}
 
+// Function Validate checks the user against the rules in its validate tags, the password is checked on the request
+// since the user only holds its hash
+func (user *User) Validate() error {
+	return validation.Struct(user)
 }
 
-// Function SaveUser saves the user to the database and ensures its not empty
+// Function SaveUser hashes the password of the user and saves the user to the database
 func (user *User) SaveUser(db *gorm.DB) (*User, error) {
-	var err error
-	err = db.Debug().Create(&user).Error
+	err := user.HashPassword()
+	if err != nil {
+		return &User{}, err
+	}
+
+	err = db.Create(&user).Error
 
 	if err != nil {
 		return &User{}, err
@@ -113,68 +91,193 @@ func (user *User) SaveUser(db *gorm.DB) (*User, error) {
 	return user, nil
 }
 
-// Function FindAllUsers queries through the database to retrieve the users but to a llmit of 100 users
-func (user *User) FindAllUsers(db *gorm.DB) (*[]User, error) {
+// UserColumns are the columns of users responses can show, the password hash is not one of them
+var UserColumns = []string{"id", "user_name", "email", "role", "version", "created_at", "updated_at"}
+
+// UserLoad says what a user query loads, the zero value loads every column and no follow counts
+type UserLoad struct {
+	Columns      []string // columns of the users, all of them when empty
+	FollowCounts bool
+}
+
+// FullUserLoad loads everything a user response shows
+var FullUserLoad = UserLoad{Columns: UserColumns, FollowCounts: true}
+
+// Function FindAllUsers queries through the database to retrieve the users but to a llmit of 100 users, with what load asks for
+func (user *User) FindAllUsers(db *gorm.DB, load UserLoad) (*[]User, error) {
 	var err error
 	users := []User{}
-	err = db.Debug().Model(&User{}).Limit(100).Find(&users).Error
+	query := db.Model(&User{})
+	if len(load.Columns) > 0 {
+		query = query.Select(load.Columns)
+	}
+	err = query.Limit(100).Find(&users).Error
 	if err != nil {
 		return &[]User{}, err
 	}
 
+	if load.FollowCounts {
+		err = LoadFollowCounts(db, users)
+		if err != nil {
+			return &[]User{}, err
+		}
+	}
+
 	return &users, err
 }
 
 // FUnction FindUserByID queries for a user using a specific ID from the users column
-// TODO: Figure out the GORM error handling method incase you
 func (user *User) FindUserByID(db *gorm.DB, uid uint32) (*User, error) {
+	return user.FindUser(db, uid, UserLoad{FollowCounts: true})
+}
+
+// Function FindUser queries for a user like FindUserByID but only loads what load asks for
+func (user *User) FindUser(db *gorm.DB, uid uint32, load UserLoad) (*User, error) {
 	var err error
-	err = db.Debug().Model(User{}).Where("id = ?", uid).Take(&user).Error
+	query := db.Model(User{})
+	if len(load.Columns) > 0 {
+		query = query.Select(load.Columns)
+	}
+	err = query.Where("id = ?", uid).Take(&user).Error
 	if err != nil {
 		return &User{}, err
 	}
 
-	//TODO: Find an error handling method to ensure that the ID actually exist
-	//if gorm.ErrInvalidValue(err) {
-	//	return &User{}, errors.New("User not found")
-	//}
+	if load.FollowCounts {
+		users := []User{*user}
+		err = LoadFollowCounts(db, users)
+		if err != nil {
+			return &User{}, err
+		}
+		*user = users[0]
+	}
 
 	return user, err
 }
 
-// Function UpdateUser bring up to date a User info
-func (user *User) UpdateUser(db *gorm.DB, uid uint32) (*User, error) {
-	err := user.BeforeSave()
+// Function FindUsersAfter returns up to limit users with an id above after, in id order
+func FindUsersAfter(db *gorm.DB, after uint32, limit int) ([]User, error) {
+	users := []User{}
+	err := db.Model(&User{}).Select(UserColumns).Where("id > ?", after).Order("id").Limit(limit).Find(&users).Error
+	return users, err
+}
+
+// Function FindUsersByIDs returns the users with the given ids that exist, without their follow counts
+func FindUsersByIDs(db *gorm.DB, uids []uint32) ([]User, error) {
+	users := []User{}
+	err := db.Model(&User{}).Select(UserColumns).Where("id IN ?", uids).Find(&users).Error
+	return users, err
+}
+
+// Function GrantRole gives the role to the users with the given emails and bumps their version. Admins are never made
+// moderators, so an email listed for both roles ends up admin whatever the order of the grants.
+func GrantRole(db *gorm.DB, role string, emails []string) (int64, error) {
+	query := db.Model(&User{}).Where("email IN ? AND role <> ?", emails, role)
+	if role != RoleAdmin {
+		query = query.Where("role <> ?", RoleAdmin)
+	}
+	result := query.UpdateColumns(map[string]interface{}{"role": role, "version": gorm.Expr("version + 1"), "updated_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+// Function UpdateUser bring up to date a User info and bumps its version. With a non zero version the update only
+// applies while the user is still at that version, otherwise it fails with a precondition error.
+func (user *User) UpdateUser(db *gorm.DB, uid uint32, version uint64) (*User, error) {
+	err := user.HashPassword()
 	if err != nil {
-		log.Fatal(err)
+		return &User{}, err
 	}
 
-	db = db.Debug().Model(&User{}).Where("id = ?", uid).Take(&User{}).UpdateColumns(
-		map[string]interface{}{
-			"password":  user.Password,
-			"user_Name": user.UserName,
-			"email":     user.Email,
-			"update_at": time.Now(),
-		},
-	)
+	return user.PatchUser(db, uid, []string{"password", "user_name", "email"}, version)
+}
 
-	if db.Error != nil {
-		return &User{}, db.Error
+// Function PatchUser writes only the named fields of a user, named as in JSON, and bumps its version like UpdateUser.
+// The password is written as it is, callers hash it first.
+func (user *User) PatchUser(db *gorm.DB, uid uint32, fields []string, version uint64) (*User, error) {
+	columns := selectColumns(map[string]interface{}{
+		"password":  user.Password,
+		"user_name": user.UserName,
+		"email":     user.Email,
+	}, fields)
+	if len(columns) > 0 {
+		err := updateVersioned(db, &User{}, uid, columns, version)
+		if err != nil {
+			return &User{}, err
+		}
 	}
 
-	err = db.Debug().Model(&User{}).Where("id = ?", uid).Take(&user).Error
+	return user.FindUserByID(db, uid)
+}
+
+// Function ChangePassword checks the current password of a user before replacing it with a new one
+func (user *User) ChangePassword(db *gorm.DB, uid uint32, current, password string) error {
+	stored := User{}
+	err := db.Model(&User{}).Select("password").Where("id = ?", uid).Take(&stored).Error
 	if err != nil {
-		return &User{}, err
+		return err
 	}
-	return user, nil
+
+	err = VerifyPassword(stored.Password, current)
+	if err != nil {
+		return apperror.Forbidden("incorrect_password", "The current password is incorrect")
+	}
+
+	user.Password = password
+	err = user.HashPassword()
+	if err != nil {
+		return err
+	}
+	return updateVersioned(db, &User{}, uid, map[string]interface{}{"password": user.Password}, 0)
 }
 
-// Function DeleteUser drops a user from the User table and returns the affected row
+// Function DeleteUser drops a user from the User table with everything that belongs to them, in one transaction: their
+// posts and what hangs off them, their comment threads, reactions, bookmarks, follows, notifications and preferences.
+// It returns the affected row.
 func (user *User) DeleteUser(db *gorm.DB, uid uint32) (int64, error) {
-	db = db.Debug().Model(&User{}).Where("id = ?", uid).Take(&User{}).Delete(&User{})
+	var rowsAffected int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&User{}).Where("id = ?", uid).Clauses(clause.Locking{Strength: "UPDATE"}).Take(&User{}).Error
+		if err != nil {
+			return err
+		}
+
+		posts := tx.Model(&Post{}).Select("id").Where("author_id = ?", uid)
+		_, err = deleteCommentTrees(tx, "author_id = ? OR post_id IN (?)", uid, posts)
+		if err != nil {
+			return err
+		}
+		for _, dependent := range []interface{}{&Reaction{}, &Bookmark{}, &Notification{}} {
+			err = tx.Where("post_id IN (?)", posts).Delete(dependent).Error
+			if err != nil {
+				return err
+			}
+		}
 
-	if db.Error != nil {
-		return 0, db.Error
+		owner := map[string]interface{}{"uid": uid}
+		for _, dependent := range []struct {
+			model     interface{}
+			condition string
+		}{
+			{&Reaction{}, "user_id = @uid"},
+			{&Bookmark{}, "user_id = @uid"},
+			{&Follow{}, "follower_id = @uid OR followee_id = @uid"},
+			{&Notification{}, "user_id = @uid OR actor_id = @uid"},
+			{&NotificationPreference{}, "user_id = @uid"},
+			{&Post{}, "author_id = @uid"},
+		} {
+			err = tx.Where(dependent.condition, owner).Delete(dependent.model).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		result := tx.Where("id = ?", uid).Delete(&User{})
+		rowsAffected = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, err
 	}
-	return db.RowsAffected, nil
+
+	return rowsAffected, nil
 }
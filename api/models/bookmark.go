@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Bookmark is a post a user saved for later
+type Bookmark struct {
+	UserID    uint32    `gorm:"primary_key" json:"user_id"`
+	PostID    uint64    `gorm:"primary_key" json:"post_id"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// Function SaveBookmark stores the bookmark, bookmarking a post twice is a no-op
+func (bookmark *Bookmark) SaveBookmark(db *gorm.DB) (*Bookmark, error) {
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&bookmark).Error
+	if err != nil {
+		return &Bookmark{}, err
+	}
+	return bookmark, nil
+}
+
+// Function DeleteBookmark removes a bookmark, removing a bookmark that does not exist is a no-op
+func (bookmark *Bookmark) DeleteBookmark(db *gorm.DB) (int64, error) {
+	db = db.Where("user_id = ? AND post_id = ?", bookmark.UserID, bookmark.PostID).Delete(&Bookmark{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// Function FindUserBookmarks returns a page of the posts a user bookmarked, most recently saved first, along with the total
+func FindUserBookmarks(db *gorm.DB, uid uint32, page, perPage int) ([]Post, int64, error) {
+	var err error
+	var total int64
+
+	err = db.Model(&Bookmark{}).Where("user_id = ?", uid).Count(&total).Error
+	if err != nil {
+		return []Post{}, 0, err
+	}
+
+	posts := []Post{}
+	err = db.Model(&Post{}).Joins("JOIN bookmarks ON bookmarks.post_id = posts.id").Where("bookmarks.user_id = ?", uid).
+		Order("bookmarks.created_at desc").Offset((page - 1) * perPage).Limit(perPage).Find(&posts).Error
+	if err != nil {
+		return []Post{}, 0, err
+	}
+
+	err = loadPostDetails(db, posts, FullPostLoad)
+	if err != nil {
+		return []Post{}, 0, err
+	}
+	return posts, total, nil
+}
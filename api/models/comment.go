@@ -0,0 +1,365 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
+	"gorm.io/gorm"
+)
+
+// MaxCommentDepth is how deep replies can be nested, a top level comment has a depth of 0
+var MaxCommentDepth = 3
+
+// Moderation states of a comment, readers only ever see approved comments
+const (
+	CommentPending  = "pending"
+	CommentApproved = "approved"
+	CommentRejected = "rejected"
+	CommentSpam     = "spam"
+)
+
+// Moderation policies deciding which new comments are held for a moderator
+const (
+	ModerateAll       = "all"
+	ModerateFirstTime = "first_time"
+	ModerateNone      = "none"
+)
+
+// ModerationPolicy is the moderation policy of the blog
+var ModerationPolicy = ModerateFirstTime
+
+type Comment struct {
+	ID        uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	Content   string     `gorm:"size:1000;not null;" json:"content" validate:"required,max=1000"`
+	PostID    uint64     `gorm:"not null;index" json:"post_id" validate:"required"`
+	Author    User       `json:"author"`
+	AuthorID  uint32     `gorm:"not null" json:"author_id" validate:"required"`
+	ParentID  *uint64    `gorm:"index" json:"parent_id"`
+	RootID    uint64     `gorm:"not null;default:0;index" json:"root_id"`
+	Depth     int        `gorm:"not null;default:0" json:"depth"`
+	Status    string     `gorm:"size:20;not null;default:approved;index" json:"status"`
+	SpamScore float64    `gorm:"not null;default:0" json:"spam_score"`
+	TrainedAs string     `gorm:"size:20;not null;default:''" json:"-"` // the decision the spam filter learnt from the comment, if any
+	Replies   []*Comment `gorm:"-" json:"replies"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+func (comment *Comment) Prepare() {
+	comment.ID = 0
+	comment.Content = html.EscapeString(strings.TrimSpace(comment.Content))
+	comment.Author = User{}
+	comment.RootID = 0
+	comment.Depth = 0
+	comment.Status = ""
+	comment.SpamScore = 0
+	comment.TrainedAs = ""
+	comment.Replies = nil
+	comment.CreatedAt = time.Now()
+	comment.UpdatedAt = time.Now()
+}
+
+// Function ValidateComment checks the comment against the rules in its validate tags
+func (comment *Comment) ValidateComment() error {
+	return validation.Struct(comment)
+}
+
+// Function SaveComment stores a comment, when it is a reply the parent is looked up to place it in the right thread
+func (comment *Comment) SaveComment(db *gorm.DB) (*Comment, error) {
+	var err error
+
+	if comment.ParentID != nil {
+		parent := Comment{}
+		err = db.Model(&Comment{}).Where("id = ? AND post_id = ? AND status = ?", *comment.ParentID, comment.PostID, CommentApproved).Take(&parent).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Comment{}, apperror.Validation("invalid_parent", "The comment replies to a comment that is not on this post",
+				validation.FieldError{Field: "parent_id", Message: "parent_id must be a published comment of the post"})
+		}
+		if err != nil {
+			return &Comment{}, err
+		}
+		if parent.Depth+1 > MaxCommentDepth {
+			return &Comment{}, apperror.Validation("reply_too_deep", "Replies cannot be nested any deeper")
+		}
+		comment.Depth = parent.Depth + 1
+		comment.RootID = parent.RootID
+	}
+
+	err = db.Model(&Comment{}).Create(&comment).Error
+	if err != nil {
+		return &Comment{}, err
+	}
+
+	// A top level comment is the root of its own thread
+	if comment.ParentID == nil {
+		comment.RootID = comment.ID
+		err = db.Model(&Comment{}).Where("id = ?", comment.ID).UpdateColumn("root_id", comment.ID).Error
+		if err != nil {
+			return &Comment{}, err
+		}
+	}
+
+	err = db.Model(&User{}).Where("id = ?", comment.AuthorID).Take(&comment.Author).Error
+	if err != nil {
+		return &Comment{}, err
+	}
+
+	return comment, nil
+}
+
+// Function FindCommentByID querries the comments table for a single comment without its replies
+func (comment *Comment) FindCommentByID(db *gorm.DB, commentid uint64) (*Comment, error) {
+	var err error
+	err = db.Model(&Comment{}).Where("id = ?", commentid).Take(&comment).Error
+	if err != nil {
+		return &Comment{}, err
+	}
+
+	err = db.Model(&User{}).Where("id = ?", comment.AuthorID).Take(&comment.Author).Error
+	if err != nil {
+		return &Comment{}, err
+	}
+
+	return comment, nil
+}
+
+// Function FindPostComments returns a page of top level comments for a post with all their replies nested under them, along with the total number of threads
+func (comment *Comment) FindPostComments(db *gorm.DB, postid uint64, page, perPage int) ([]*Comment, int64, error) {
+	var err error
+	var total int64
+
+	err = db.Model(&Comment{}).Where("post_id = ? AND parent_id IS NULL AND status = ?", postid, CommentApproved).Count(&total).Error
+	if err != nil {
+		return []*Comment{}, 0, err
+	}
+
+	roots := []*Comment{}
+	err = db.Model(&Comment{}).Where("post_id = ? AND parent_id IS NULL AND status = ?", postid, CommentApproved).
+		Order("created_at asc").Offset((page - 1) * perPage).Limit(perPage).Find(&roots).Error
+	if err != nil {
+		return []*Comment{}, 0, err
+	}
+	err = loadThreads(db, roots)
+	if err != nil {
+		return []*Comment{}, 0, err
+	}
+	return roots, total, nil
+}
+
+// Function FindPostsThreads returns a page of the top level comments of each of the given posts, in id order and with
+// their replies nested under them. A single query pages every post, ranking the comments of each one.
+func FindPostsThreads(db *gorm.DB, postids []uint64, after uint64, limit int) (map[uint64][]*Comment, error) {
+	threads := make(map[uint64][]*Comment, len(postids))
+	if len(postids) == 0 {
+		return threads, nil
+	}
+
+	ranked := db.Model(&Comment{}).Select("comments.*, ROW_NUMBER() OVER (PARTITION BY post_id ORDER BY id) AS position").
+		Where("post_id IN ? AND parent_id IS NULL AND status = ? AND id > ?", postids, CommentApproved, after)
+	roots := []*Comment{}
+	err := db.Table("(?) AS ranked", ranked).Where("position <= ?", limit).Order("id").Find(&roots).Error
+	if err != nil {
+		return threads, err
+	}
+
+	err = loadThreads(db, roots)
+	if err != nil {
+		return threads, err
+	}
+	for _, root := range roots {
+		threads[root.PostID] = append(threads[root.PostID], root)
+	}
+	return threads, nil
+}
+
+// Function CountPostsThreads returns the number of top level comments on each of the given posts
+func CountPostsThreads(db *gorm.DB, postids []uint64) (map[uint64]int64, error) {
+	return countComments(db.Where("parent_id IS NULL"), postids)
+}
+
+// Function loadThreads nests the approved replies of top level comments under them and fills in every author
+func loadThreads(db *gorm.DB, roots []*Comment) error {
+	if len(roots) == 0 {
+		return nil
+	}
+
+	rootIDs := make([]uint64, len(roots))
+	for i := range roots {
+		rootIDs[i] = roots[i].ID
+	}
+
+	replies := []*Comment{}
+	err := db.Model(&Comment{}).Where("root_id IN ? AND parent_id IS NOT NULL AND status = ?", rootIDs, CommentApproved).
+		Order("depth asc, created_at asc").Find(&replies).Error
+	if err != nil {
+		return err
+	}
+
+	all := append(append([]*Comment{}, roots...), replies...)
+	err = loadCommentAuthors(db, all)
+	if err != nil {
+		return err
+	}
+
+	// Replies are ordered by depth so a parent is always indexed before its children
+	byID := make(map[uint64]*Comment, len(all))
+	for _, c := range roots {
+		c.Replies = []*Comment{}
+		byID[c.ID] = c
+	}
+	for _, c := range replies {
+		c.Replies = []*Comment{}
+		byID[c.ID] = c
+		if parent, ok := byID[*c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, c)
+		}
+	}
+	return nil
+}
+
+// Function NeedsModeration applies the moderation policy to decide whether the comment must wait for a moderator
+func (comment *Comment) NeedsModeration(db *gorm.DB) (bool, error) {
+	switch ModerationPolicy {
+	case ModerateNone:
+		return false, nil
+
+	case ModerateFirstTime:
+		var approved int64
+		err := db.Model(&Comment{}).Where("author_id = ? AND status = ?", comment.AuthorID, CommentApproved).Count(&approved).Error
+		if err != nil {
+			return true, err
+		}
+		return approved == 0, nil
+
+	default:
+		return true, nil
+	}
+}
+
+// Function FindCommentsByStatus returns a page of comments in the given moderation state, oldest first, along with the total
+func (comment *Comment) FindCommentsByStatus(db *gorm.DB, status string, page, perPage int) ([]*Comment, int64, error) {
+	var err error
+	var total int64
+
+	err = db.Model(&Comment{}).Where("status = ?", status).Count(&total).Error
+	if err != nil {
+		return []*Comment{}, 0, err
+	}
+
+	comments := []*Comment{}
+	err = db.Model(&Comment{}).Where("status = ?", status).
+		Order("created_at asc").Offset((page - 1) * perPage).Limit(perPage).Find(&comments).Error
+	if err != nil {
+		return []*Comment{}, 0, err
+	}
+
+	err = loadCommentAuthors(db, comments)
+	if err != nil {
+		return []*Comment{}, 0, err
+	}
+
+	return comments, total, nil
+}
+
+// Function UpdateCommentStatus moves a comment to another moderation state
+func (comment *Comment) UpdateCommentStatus(db *gorm.DB, status string) (*Comment, error) {
+	var err error
+	err = db.Model(&Comment{}).Where("id = ?", comment.ID).UpdateColumn("status", status).Error
+	if err != nil {
+		return &Comment{}, err
+	}
+
+	return comment.FindCommentByID(db, comment.ID)
+}
+
+// Function UpdateTrainedAs records the decision the spam filter learnt from the comment, "" when it learnt none
+func (comment *Comment) UpdateTrainedAs(db *gorm.DB, status string) error {
+	return db.Model(&Comment{}).Where("id = ?", comment.ID).UpdateColumn("trained_as", status).Error
+}
+
+// Function UpdateComment modifies the content of a comment along with its spam score and status, forgets what the spam
+// filter learnt from the old content and returns the updated comment
+func (comment *Comment) UpdateComment(db *gorm.DB) (*Comment, error) {
+	var err error
+	err = db.Model(&Comment{}).Where("id = ?", comment.ID).Updates(map[string]interface{}{
+		"content":    comment.Content,
+		"spam_score": comment.SpamScore,
+		"status":     comment.Status,
+		"trained_as": "",
+		"updated_at": time.Now(),
+	}).Error
+	if err != nil {
+		return &Comment{}, err
+	}
+
+	return comment.FindCommentByID(db, comment.ID)
+}
+
+// Function DeleteComment drops a comment together with every reply nested under it and returns the rows affected
+func (comment *Comment) DeleteComment(db *gorm.DB, commentid uint64) (int64, error) {
+	return deleteCommentTrees(db, "id = ?", commentid)
+}
+
+// Function deleteCommentTrees drops the comments the condition selects together with every reply nested under them,
+// however deep, and returns the number of comments dropped
+func deleteCommentTrees(db *gorm.DB, condition string, args ...interface{}) (int64, error) {
+	result := db.Exec(`WITH RECURSIVE tree AS (
+		SELECT id FROM comments WHERE `+condition+`
+		UNION
+		SELECT comments.id FROM comments JOIN tree ON comments.parent_id = tree.id
+	) DELETE FROM comments WHERE id IN (SELECT id FROM tree)`, args...)
+	return result.RowsAffected, result.Error
+}
+
+// Function CountPostComments returns the number of comments on each of the given posts
+func CountPostComments(db *gorm.DB, postids []uint64) (map[uint64]int64, error) {
+	return countComments(db, postids)
+}
+
+// Function countComments counts the approved comments of each post that the conditions already on db select
+func countComments(db *gorm.DB, postids []uint64) (map[uint64]int64, error) {
+	counts := make(map[uint64]int64, len(postids))
+	if len(postids) == 0 {
+		return counts, nil
+	}
+
+	rows := []struct {
+		PostID uint64
+		Count  int64
+	}{}
+	err := db.Model(&Comment{}).Select("post_id, count(*) as count").Where("post_id IN ? AND status = ?", postids, CommentApproved).Group("post_id").Scan(&rows).Error
+	if err != nil {
+		return counts, err
+	}
+
+	for _, row := range rows {
+		counts[row.PostID] = row.Count
+	}
+	return counts, nil
+}
+
+func loadCommentAuthors(db *gorm.DB, comments []*Comment) error {
+	authorIDs := []uint32{}
+	for _, c := range comments {
+		authorIDs = append(authorIDs, c.AuthorID)
+	}
+
+	authors := []User{}
+	err := db.Model(&User{}).Where("id IN ?", authorIDs).Find(&authors).Error
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[uint32]User, len(authors))
+	for _, a := range authors {
+		byID[a.ID] = a
+	}
+	for _, c := range comments {
+		c.Author = byID[c.AuthorID]
+	}
+	return nil
+}
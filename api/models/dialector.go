@@ -0,0 +1,22 @@
+package models
+
+import (
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// postgresDialector is the Postgres dialector without its error translation. gorm would turn unique violations into
+// gorm.ErrDuplicatedKey, dropping the constraint formaterror needs to name the field that is taken.
+type postgresDialector struct {
+	*postgres.Dialector
+}
+
+// Function Postgres returns the dialector the database is opened with
+func Postgres(config postgres.Config) gorm.Dialector {
+	return postgresDialector{Dialector: &postgres.Dialector{Config: &config}}
+}
+
+// Function Translate returns the errors of the driver as they are
+func (dialector postgresDialector) Translate(err error) error {
+	return err
+}
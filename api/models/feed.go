@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"gorm.io/gorm"
+)
+
+// FeedCursor points at the last post of a feed page, the next page starts right after it
+type FeedCursor struct {
+	CreatedAt time.Time
+	ID        uint64
+}
+
+// Function Encode turns the cursor into the opaque string handed to clients
+func (cursor FeedCursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", cursor.CreatedAt.UnixNano(), cursor.ID)))
+}
+
+// Function DecodeFeedCursor parses a cursor previously returned by Encode
+func DecodeFeedCursor(value string) (*FeedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, apperror.BadRequest("invalid_cursor", "Invalid cursor")
+	}
+
+	var nanos int64
+	var id uint64
+	_, err = fmt.Sscanf(string(raw), "%d:%d", &nanos, &id)
+	if err != nil {
+		return nil, apperror.BadRequest("invalid_cursor", "Invalid cursor")
+	}
+	return &FeedCursor{CreatedAt: time.Unix(0, nanos), ID: id}, nil
+}
+
+// Function FindFeed returns the most recent posts of the authors uid follows, starting after the cursor when one is given.
+// The feed is built on read by joining follows against the (author_id, created_at) index on posts.
+func FindFeed(db *gorm.DB, uid uint32, cursor *FeedCursor, limit int) ([]Post, *FeedCursor, error) {
+	query := db.Model(&Post{}).
+		Joins("JOIN follows ON follows.followee_id = posts.author_id").
+		Where("follows.follower_id = ?", uid)
+	if cursor != nil {
+		query = query.Where("(posts.created_at, posts.id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	// One extra post is fetched to know whether there is a next page
+	posts := []Post{}
+	err := query.Order("posts.created_at desc, posts.id desc").Limit(limit + 1).Find(&posts).Error
+	if err != nil {
+		return []Post{}, nil, err
+	}
+
+	var next *FeedCursor
+	if len(posts) > limit {
+		posts = posts[:limit]
+		last := posts[len(posts)-1]
+		next = &FeedCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	err = loadPostDetails(db, posts, FullPostLoad)
+	if err != nil {
+		return []Post{}, nil, err
+	}
+	return posts, next, nil
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+func TestFeedIndex(t *testing.T) {
+	posts, err := schema.Parse(&Post{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatalf("cannot parse the posts schema: %v", err)
+	}
+
+	index, ok := posts.ParseIndexes()["idx_posts_author_created"]
+	if !ok {
+		t.Fatal("posts have no idx_posts_author_created index")
+	}
+	columns := []string{}
+	for _, field := range index.Fields {
+		columns = append(columns, field.DBName)
+	}
+	if len(columns) != 2 || columns[0] != "author_id" || columns[1] != "created_at" {
+		t.Errorf("idx_posts_author_created is on %v, want [author_id created_at]", columns)
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Follow records that a user follows another user, the primary key serves the following lists and the followee index the followers lists
+type Follow struct {
+	FollowerID uint32    `gorm:"primary_key" json:"follower_id"`
+	FolloweeID uint32    `gorm:"primary_key;index:idx_follows_followee" json:"followee_id"`
+	CreatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// Function SaveFollow stores the follow, following a user twice is a no-op
+func (follow *Follow) SaveFollow(db *gorm.DB) (*Follow, error) {
+	if follow.FollowerID == follow.FolloweeID {
+		return &Follow{}, apperror.Validation("cannot_follow_self", "You cannot follow yourself")
+	}
+
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&follow).Error
+	if err != nil {
+		return &Follow{}, err
+	}
+	return follow, nil
+}
+
+// Function DeleteFollow removes a follow, unfollowing a user that is not followed is a no-op
+func (follow *Follow) DeleteFollow(db *gorm.DB) (int64, error) {
+	db = db.Where("follower_id = ? AND followee_id = ?", follow.FollowerID, follow.FolloweeID).Delete(&Follow{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// Function FindFollowers returns a page of the users following uid, most recent first, along with the total
+func FindFollowers(db *gorm.DB, uid uint32, page, perPage int) ([]User, int64, error) {
+	return findFollowUsers(db, "follows.followee_id = ?", "follows.follower_id", uid, page, perPage)
+}
+
+// Function FindFollowing returns a page of the users uid follows, most recent first, along with the total
+func FindFollowing(db *gorm.DB, uid uint32, page, perPage int) ([]User, int64, error) {
+	return findFollowUsers(db, "follows.follower_id = ?", "follows.followee_id", uid, page, perPage)
+}
+
+func findFollowUsers(db *gorm.DB, where, joinColumn string, uid uint32, page, perPage int) ([]User, int64, error) {
+	var err error
+	var total int64
+
+	err = db.Model(&Follow{}).Where(where, uid).Count(&total).Error
+	if err != nil {
+		return []User{}, 0, err
+	}
+
+	users := []User{}
+	err = db.Model(&User{}).Joins("JOIN follows ON "+joinColumn+" = users.id").Where(where, uid).
+		Order("follows.created_at desc").Offset((page - 1) * perPage).Limit(perPage).Find(&users).Error
+	if err != nil {
+		return []User{}, 0, err
+	}
+
+	err = LoadFollowCounts(db, users)
+	if err != nil {
+		return []User{}, 0, err
+	}
+	return users, total, nil
+}
+
+// Function LoadFollowCounts fills in how many followers each user has and how many users they follow
+func LoadFollowCounts(db *gorm.DB, users []User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	uids := make([]uint32, len(users))
+	for i := range users {
+		uids[i] = users[i].ID
+	}
+
+	rows := []struct {
+		ID    uint32
+		Count int64
+	}{}
+	followers := map[uint32]int64{}
+	err := db.Model(&Follow{}).Select("followee_id as id, count(*) as count").Where("followee_id IN ?", uids).Group("followee_id").Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		followers[row.ID] = row.Count
+	}
+
+	rows = rows[:0]
+	following := map[uint32]int64{}
+	err = db.Model(&Follow{}).Select("follower_id as id, count(*) as count").Where("follower_id IN ?", uids).Group("follower_id").Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		following[row.ID] = row.Count
+	}
+
+	for i := range users {
+		users[i].FollowersCount = followers[users[i].ID]
+		users[i].FollowingCount = following[users[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Function Tables lists every model stored in the database, a model is listed after the models it references
+func Tables() []interface{} {
+	return []interface{}{&User{}, &Post{}, &Comment{}, &SpamToken{}, &Reaction{}, &Bookmark{}, &Follow{}, &Notification{}, &NotificationPreference{}}
+}
+
+// Function PendingMigrations lists the tables and columns of the models that are missing from the database
+func PendingMigrations(db *gorm.DB) ([]string, error) {
+	pending := []string{}
+	migrator := db.Migrator()
+	for _, model := range Tables() {
+		stmt := &gorm.Statement{DB: db}
+		err := stmt.Parse(model)
+		if err != nil {
+			return nil, err
+		}
+
+		if !migrator.HasTable(model) {
+			pending = append(pending, stmt.Schema.Table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !migrator.HasColumn(model, field.DBName) {
+				pending = append(pending, fmt.Sprintf("%s.%s", stmt.Schema.Table, field.DBName))
+			}
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Events a user can be notified about
+const (
+	NotificationComment       = "comment"
+	NotificationReply         = "reply"
+	NotificationFollow        = "follow"
+	NotificationPostPublished = "post_published"
+)
+
+// NotificationTypes lists every event type, in the order preferences are shown
+var NotificationTypes = []string{NotificationComment, NotificationReply, NotificationFollow, NotificationPostPublished}
+
+type Notification struct {
+	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
+	UserID    uint32    `gorm:"not null;index:idx_notifications_user_created,priority:1" json:"user_id"`
+	Type      string    `gorm:"size:30;not null" json:"type"`
+	Actor     User      `json:"actor"`
+	ActorID   uint32    `gorm:"not null" json:"actor_id"`
+	PostID    *uint64   `json:"post_id"`
+	CommentID *uint64   `json:"comment_id"`
+	Read      bool      `gorm:"not null;default:false" json:"read"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP;index:idx_notifications_user_created,priority:2" json:"created_at"`
+}
+
+// NotificationPreference decides whether an event type also sends an email to the user
+type NotificationPreference struct {
+	UserID uint32 `gorm:"primary_key" json:"-"`
+	Type   string `gorm:"primary_key;size:30" json:"type" validate:"required,oneof=comment|reply|follow|post_published"`
+	Email  bool   `gorm:"not null;default:false" json:"email"`
+}
+
+// Function SaveNotifications stores a batch of notifications and fills in their actors
+func SaveNotifications(db *gorm.DB, notifications []Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	err := db.Model(&Notification{}).Omit("Actor").CreateInBatches(&notifications, 500).Error
+	if err != nil {
+		return err
+	}
+
+	actor := User{}
+	err = db.Model(&User{}).Where("id = ?", notifications[0].ActorID).Take(&actor).Error
+	if err != nil {
+		return err
+	}
+	for i := range notifications {
+		if notifications[i].ActorID == actor.ID {
+			notifications[i].Actor = actor
+		}
+	}
+	return nil
+}
+
+// Function FindNotifications returns a page of the notifications of a user, newest first, along with the total
+func FindNotifications(db *gorm.DB, uid uint32, unreadOnly bool, page, perPage int) ([]Notification, int64, error) {
+	var err error
+	var total int64
+
+	query := db.Model(&Notification{}).Where("user_id = ?", uid)
+	if unreadOnly {
+		query = query.Where("read = ?", false)
+	}
+
+	err = query.Count(&total).Error
+	if err != nil {
+		return []Notification{}, 0, err
+	}
+
+	notifications := []Notification{}
+	err = query.Preload("Actor").Order("created_at desc, id desc").Offset((page - 1) * perPage).Limit(perPage).Find(&notifications).Error
+	if err != nil {
+		return []Notification{}, 0, err
+	}
+	return notifications, total, nil
+}
+
+// Function MarkNotificationsRead marks the notifications of a user as read, every notification when no id is given
+func MarkNotificationsRead(db *gorm.DB, uid uint32, ids ...uint64) (int64, error) {
+	query := db.Model(&Notification{}).Where("user_id = ? AND read = ?", uid, false)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+
+	query = query.UpdateColumn("read", true)
+	if query.Error != nil {
+		return 0, query.Error
+	}
+	return query.RowsAffected, nil
+}
+
+// Function FindNotificationPreferences returns the preference of a user for every event type, unset types default to no email
+func FindNotificationPreferences(db *gorm.DB, uid uint32) ([]NotificationPreference, error) {
+	stored := []NotificationPreference{}
+	err := db.Model(&NotificationPreference{}).Where("user_id = ?", uid).Find(&stored).Error
+	if err != nil {
+		return []NotificationPreference{}, err
+	}
+
+	byType := map[string]bool{}
+	for _, preference := range stored {
+		byType[preference.Type] = preference.Email
+	}
+
+	preferences := make([]NotificationPreference, len(NotificationTypes))
+	for i, kind := range NotificationTypes {
+		preferences[i] = NotificationPreference{UserID: uid, Type: kind, Email: byType[kind]}
+	}
+	return preferences, nil
+}
+
+// Function SaveNotificationPreferences stores the preferences of a user, overwriting the previous ones
+func SaveNotificationPreferences(db *gorm.DB, preferences []NotificationPreference) error {
+	if len(preferences) == 0 {
+		return nil
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"email"}),
+	}).Create(&preferences).Error
+}
+
+// Function FindEmailRecipients returns which of the given users want an email for the event type
+func FindEmailRecipients(db *gorm.DB, kind string, uids []uint32) ([]User, error) {
+	users := []User{}
+	if len(uids) == 0 {
+		return users, nil
+	}
+
+	err := db.Model(&User{}).
+		Joins("JOIN notification_preferences ON notification_preferences.user_id = users.id").
+		Where("users.id IN ? AND notification_preferences.type = ? AND notification_preferences.email = ?", uids, kind, true).
+		Find(&users).Error
+	if err != nil {
+		return []User{}, err
+	}
+	return users, nil
+}
+
+// Function FindFollowerIDs returns the ids of every user following uid
+func FindFollowerIDs(db *gorm.DB, uid uint32) ([]uint32, error) {
+	ids := []uint32{}
+	err := db.Model(&Follow{}).Where("followee_id = ?", uid).Pluck("follower_id", &ids).Error
+	if err != nil {
+		return []uint32{}, err
+	}
+	return ids, nil
+}
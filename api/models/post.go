@@ -6,17 +6,23 @@ import (
 	"strings"
 	"time"
 
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Post struct {
-	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
-	Title     string    `gorm:"size:255;not null;unique" json:"title"`
-	Content   string    `gorm:"size:255;not null;" json:"content"`
-	Author    User      `json:"author"`
-	AuthorID  uint32    `gorm:"not null" json:"author_id"`
-	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	ID              uint64           `gorm:"primary_key;auto_increment" json:"id"`
+	Title           string           `gorm:"size:255;not null;unique" json:"title" validate:"required,max=255"`
+	Content         string           `gorm:"size:255;not null;" json:"content" validate:"required,max=255"`
+	Author          User             `json:"author"`
+	AuthorID        uint32           `gorm:"not null;index:idx_posts_author_created,priority:1" json:"author_id" validate:"required"`
+	CommentCount    int64            `gorm:"-" json:"comment_count"`
+	Reactions       map[string]int64 `gorm:"-" json:"reactions"`
+	ViewerReactions []string         `gorm:"-" json:"viewer_reactions"`
+	Version         uint64           `gorm:"not null;default:1" json:"version"`
+	CreatedAt       time.Time        `gorm:"default:CURRENT_TIMESTAMP;index:idx_posts_author_created,priority:2" json:"created_at"`
+	UpdatedAt       time.Time        `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 }
 
 func (post *Post) Prepare() {
@@ -24,35 +30,26 @@ func (post *Post) Prepare() {
 	post.Title = html.EscapeString(strings.TrimSpace(post.Title))
 	post.Content = html.UnescapeString(strings.TrimSpace(post.Content))
 	post.Author = User{}
+	post.Version = 1
 	post.CreatedAt = time.Now()
 	post.UpdatedAt = time.Now()
 }
 
-// Function ValidatePost ensures the correct info is what is provided
+// Function ValidatePost checks the post against the rules in its validate tags
 func (post *Post) ValidatePost() error {
-	if post.Title == "" {
-		return errors.New("Title required")
-	}
-	if post.Content == "" {
-		return errors.New("Title is required")
-	}
-	if post.AuthorID < 1 {
-		return errors.New("Author is required")
-	}
-
-	return nil
+	return validation.Struct(post)
 }
 
 // Function SavePost stores a post to the BD
 func (post *Post) SavePost(db *gorm.DB) (*Post, error) {
 	var err error
-	err = db.Debug().Model(&Post{}).Create(&post).Error
+	err = db.Model(&Post{}).Create(&post).Error
 	if err != nil {
 		return &Post{}, err
 	}
 
 	if post.ID != 0 {
-		err = db.Debug().Model(&User{}).Where("id = ?", post.AuthorID).Take(&post.AuthorID).Error
+		err = db.Model(&User{}).Select(UserColumns).Where("id = ?", post.AuthorID).Take(&post.Author).Error
 		if err != nil {
 			return &Post{}, err
 		}
@@ -61,71 +58,221 @@ func (post *Post) SavePost(db *gorm.DB) (*Post, error) {
 	return post, nil
 }
 
-// Function FindAllPosts querries through the post table to return all the posts that were created
-func (post *Post) FIndAllPosts(db *gorm.DB) (*[]Post, error) {
+// PostColumns are the columns of posts responses can show
+var PostColumns = []string{"id", "title", "content", "author_id", "version", "created_at", "updated_at"}
+
+// PostLoad says what a post query loads, the zero value loads every column of the posts and nothing related to them
+type PostLoad struct {
+	Columns       []string // columns of the posts, all of them when empty
+	AuthorColumns []string // columns of the authors, who are not loaded when empty
+	CommentCount  bool
+}
+
+// FullPostLoad loads everything a post response shows but its reactions, which depend on the viewer
+var FullPostLoad = PostLoad{AuthorColumns: UserColumns, CommentCount: true}
+
+// Function FindAllPosts querries through the post table to return all the posts that were created, with what load asks for
+func (post *Post) FIndAllPosts(db *gorm.DB, load PostLoad) (*[]Post, error) {
 	var err error
 	posts := []Post{}
-	err = db.Debug().Model(&Post{}).Limit(100).Find(&posts).Error
+	query := db.Model(&Post{})
+	if len(load.Columns) > 0 {
+		query = query.Select(load.Columns)
+	}
+	err = query.Limit(100).Find(&posts).Error
 	if err != nil {
 		return &[]Post{}, err
 	}
 
-	if len(posts) > 0 {
-		for i := range posts {
-			err := db.Debug().Model(&User{}).Where("id = ?", posts[i].AuthorID).Take(&posts[i].Author).Error
-			if err != nil {
-				return &[]Post{}, err
-			}
-		}
+	err = loadPostDetails(db, posts, load)
+	if err != nil {
+		return &[]Post{}, err
 	}
 	return &posts, nil
 }
 
-// Function FindPostByID querries through the table to locate a post and return the post
-func (post *Post) FIndPostByID(db gorm.DB, postid uint64) (*Post, error) {
-	var err error
-	err = db.Debug().Model(&Post{}).Where("id = ?", postid).Take(&post).Error
+// Function FindPostsAfter returns up to limit posts with an id above after, in id order, with what load asks for
+func FindPostsAfter(db *gorm.DB, after uint64, limit int, load PostLoad) ([]Post, error) {
+	posts := []Post{}
+	query := db.Model(&Post{})
+	if len(load.Columns) > 0 {
+		query = query.Select(load.Columns)
+	}
+	err := query.Where("id > ?", after).Order("id").Limit(limit).Find(&posts).Error
 	if err != nil {
-		return &Post{}, err
+		return posts, err
 	}
+	return posts, loadPostDetails(db, posts, load)
+}
 
-	if post.ID != 0 {
-		err = db.Debug().Model(&User{}).Where("id = ?", post.AuthorID).Take(&post.Author).Error
+// Function FindAuthorsPosts returns up to limit posts with an id above after of each of the given authors, in id order.
+// A single query pages every author, ranking the posts of each one.
+func FindAuthorsPosts(db *gorm.DB, uids []uint32, after uint64, limit int) (map[uint32][]Post, error) {
+	byAuthor := make(map[uint32][]Post, len(uids))
+	if len(uids) == 0 {
+		return byAuthor, nil
+	}
+
+	ranked := db.Model(&Post{}).Select("posts.*, ROW_NUMBER() OVER (PARTITION BY author_id ORDER BY id) AS position").
+		Where("author_id IN ? AND id > ?", uids, after)
+	posts := []Post{}
+	err := db.Table("(?) AS ranked", ranked).Where("position <= ?", limit).Order("id").Find(&posts).Error
+	if err != nil {
+		return byAuthor, err
+	}
+	for _, post := range posts {
+		byAuthor[post.AuthorID] = append(byAuthor[post.AuthorID], post)
+	}
+	return byAuthor, nil
+}
+
+// Function CountAuthorsPosts returns the number of posts of each of the given authors
+func CountAuthorsPosts(db *gorm.DB, uids []uint32) (map[uint32]int64, error) {
+	counts := make(map[uint32]int64, len(uids))
+	if len(uids) == 0 {
+		return counts, nil
+	}
+
+	rows := []struct {
+		AuthorID uint32
+		Count    int64
+	}{}
+	err := db.Model(&Post{}).Select("author_id, count(*) as count").Where("author_id IN ?", uids).Group("author_id").Scan(&rows).Error
+	if err != nil {
+		return counts, err
+	}
+	for _, row := range rows {
+		counts[row.AuthorID] = row.Count
+	}
+	return counts, nil
+}
+
+// Function FindPostsByIDs returns the posts with the given ids that exist, without anything related to them
+func FindPostsByIDs(db *gorm.DB, postids []uint64) ([]Post, error) {
+	posts := []Post{}
+	err := db.Model(&Post{}).Where("id IN ?", postids).Find(&posts).Error
+	return posts, err
+}
+
+// Function loadPostDetails fills in the author and comment count of each post in a list when load asks for them
+func loadPostDetails(db *gorm.DB, posts []Post, load PostLoad) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	if len(load.AuthorColumns) > 0 {
+		uids := []uint32{}
+		for i := range posts {
+			uids = append(uids, posts[i].AuthorID)
+		}
+		authors := []User{}
+		err := db.Model(&User{}).Select(load.AuthorColumns).Where("id IN ?", uids).Find(&authors).Error
 		if err != nil {
-			return &Post{}, err
+			return err
+		}
+		byID := make(map[uint32]User, len(authors))
+		for _, author := range authors {
+			byID[author.ID] = author
+		}
+		for i := range posts {
+			posts[i].Author = byID[posts[i].AuthorID]
 		}
 	}
 
-	return post, nil
+	if !load.CommentCount {
+		return nil
+	}
+	postids := make([]uint64, len(posts))
+	for i := range posts {
+		postids[i] = posts[i].ID
+	}
+	counts, err := CountPostComments(db, postids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].CommentCount = counts[posts[i].ID]
+	}
+	return nil
 }
 
-// FUnction UpdatePost modifies a post by querrying through the table using a specific ID and returns the updated post
-func (post *Post) UpdatePost(db gorm.DB) (*Post, error) {
-	var err error
-	err = db.Debug().Model(&Post{}).Where("id = ?", post.ID).Updates(Post{Title: post.Title, Content: post.Content, UpdatedAt: time.Now()}).Error
+// Function FindPostByID querries through the table to locate a post and return the post with its author and comment count
+func (post *Post) FIndPostByID(db gorm.DB, postid uint64) (*Post, error) {
+	return post.FindPost(db, postid, FullPostLoad)
+}
+
+// Function FindPost locates a post like FindPostByID but only loads what load asks for
+func (post *Post) FindPost(db gorm.DB, postid uint64, load PostLoad) (*Post, error) {
+	query := db.Model(&Post{})
+	if len(load.Columns) > 0 {
+		query = query.Select(load.Columns)
+	}
+	err := query.Where("id = ?", postid).Take(&post).Error
 	if err != nil {
 		return &Post{}, err
 	}
 
-	if post.ID != 0 {
-		err = db.Debug().Model(&User{}).Where("id = ?", post.AuthorID).Take(&post.Author).Error
+	posts := []Post{*post}
+	err = loadPostDetails(&db, posts, load)
+	if err != nil {
+		return &Post{}, err
+	}
+	*post = posts[0]
+	return post, nil
+}
+
+// Function UpdatePost replaces the title and content of a post and bumps its version. With a non zero version the
+// update only applies while the post is still at that version, otherwise it fails with a precondition error.
+func (post *Post) UpdatePost(db gorm.DB, version uint64) (*Post, error) {
+	return post.PatchPost(db, []string{"title", "content"}, version)
+}
+
+// Function PatchPost writes only the named fields of a post, named as in JSON, and bumps its version like UpdatePost
+func (post *Post) PatchPost(db gorm.DB, fields []string, version uint64) (*Post, error) {
+	columns := selectColumns(map[string]interface{}{
+		"title":   post.Title,
+		"content": post.Content,
+	}, fields)
+	if len(columns) > 0 {
+		err := updateVersioned(&db, &Post{}, post.ID, columns, version)
 		if err != nil {
 			return &Post{}, err
 		}
 	}
 
-	return post, nil
+	return post.FIndPostByID(db, post.ID)
 }
 
-// Function DeletePost drops a post after querying for a specific ID and the returning the rows affected by dropping the post
-func (post *Post) DeletePost(db gorm.DB, postid uint64, userid uint32) (int64, error) {
-	db = *db.Debug().Model(&Post{}).Where("id = ?", postid, userid).Take(&Post{}).Delete(&Post{})
-	if db.Error != nil {
-		// if gorm.ErrRecordNotFound(db.Error) {
-		// 	return 0, errors.New("Post Not Found")
-		// }
-		return 0, db.Error
+// Function DeletePost drops a post of the given author together with its comments, reactions, bookmarks and notifications, returning the rows affected by dropping the post
+func (post *Post) DeletePost(db gorm.DB, postid uint64, userid uint32, version uint64) (int64, error) {
+	var rowsAffected int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&Post{}).Where("id = ? AND author_id = ?", postid, userid)
+		if version != 0 {
+			query = query.Where("version = ?", version)
+		}
+		err := query.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&Post{}).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) && version != 0 {
+			return ErrStaleVersion
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, dependent := range []interface{}{&Comment{}, &Reaction{}, &Bookmark{}, &Notification{}} {
+			err = tx.Where("post_id = ?", postid).Delete(dependent).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		result := tx.Where("id = ? AND author_id = ?", postid, userid).Delete(&Post{})
+		rowsAffected = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return db.RowsAffected, nil
+	return rowsAffected, nil
 }
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reactions are the fixed set of reactions readers can leave on a post, keyed by the name used in the API
+var Reactions = map[string]string{
+	"like":      "👍",
+	"love":      "❤️",
+	"laugh":     "😂",
+	"wow":       "😮",
+	"sad":       "😢",
+	"celebrate": "🎉",
+}
+
+// Reaction is a single reaction of a user on a post, a user can leave each reaction only once per post
+type Reaction struct {
+	PostID    uint64    `gorm:"primary_key" json:"post_id"`
+	UserID    uint32    `gorm:"primary_key" json:"user_id"`
+	Name      string    `gorm:"primary_key;size:20" json:"name"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// Function SaveReaction stores the reaction, reacting twice with the same reaction is a no-op
+func (reaction *Reaction) SaveReaction(db *gorm.DB) (*Reaction, error) {
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction).Error
+	if err != nil {
+		return &Reaction{}, err
+	}
+	return reaction, nil
+}
+
+// Function DeleteReaction removes the reaction of a user on a post, removing a reaction that does not exist is a no-op
+func (reaction *Reaction) DeleteReaction(db *gorm.DB) (int64, error) {
+	db = db.Where("post_id = ? AND user_id = ? AND name = ?", reaction.PostID, reaction.UserID, reaction.Name).Delete(&Reaction{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// Function LoadReactions fills in the reaction counts of each post and the reactions left by the viewer, a viewer of 0 is anonymous
+func LoadReactions(db *gorm.DB, posts []Post, viewer uint32) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	postids := make([]uint64, len(posts))
+	for i := range posts {
+		postids[i] = posts[i].ID
+		posts[i].Reactions = map[string]int64{}
+		posts[i].ViewerReactions = []string{}
+	}
+
+	rows := []struct {
+		PostID uint64
+		Name   string
+		Count  int64
+	}{}
+	err := db.Model(&Reaction{}).Select("post_id, name, count(*) as count").Where("post_id IN ?", postids).Group("post_id, name").Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	own := []Reaction{}
+	if viewer != 0 {
+		err = db.Model(&Reaction{}).Where("post_id IN ? AND user_id = ?", postids, viewer).Order("created_at asc").Find(&own).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	byID := make(map[uint64]*Post, len(posts))
+	for i := range posts {
+		byID[posts[i].ID] = &posts[i]
+	}
+	for _, row := range rows {
+		byID[row.PostID].Reactions[row.Name] = row.Count
+	}
+	for _, reaction := range own {
+		byID[reaction.PostID].ViewerReactions = append(byID[reaction.PostID].ViewerReactions, reaction.Name)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SpamDocumentsToken is the reserved row holding how many spam and ham comments the classifier was trained on
+const SpamDocumentsToken = "#documents"
+
+// SpamToken keeps how many times a word was seen in comments moderators marked as spam or approved
+type SpamToken struct {
+	Token string `gorm:"primary_key;size:64" json:"token"`
+	Spam  int64  `gorm:"not null;default:0" json:"spam"`
+	Ham   int64  `gorm:"not null;default:0" json:"ham"`
+}
+
+// Function FindAllSpamTokens loads every token the classifier has been trained on
+func FindAllSpamTokens(db *gorm.DB) ([]SpamToken, error) {
+	tokens := []SpamToken{}
+	err := db.Model(&SpamToken{}).Find(&tokens).Error
+	if err != nil {
+		return []SpamToken{}, err
+	}
+	return tokens, nil
+}
+
+// Function IncrementSpamTokens adds the given counts to the stored tokens, creating the ones that do not exist yet
+func IncrementSpamTokens(db *gorm.DB, tokens []SpamToken) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "token"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"spam": gorm.Expr("spam_tokens.spam + excluded.spam"),
+			"ham":  gorm.Expr("spam_tokens.ham + excluded.ham"),
+		}),
+	}).Create(&tokens).Error
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"gorm.io/gorm"
+)
+
+// ErrStaleVersion is returned by conditional writes whose expected version is no longer the current one, whether the
+// If-Match header or the write itself finds it out
+var ErrStaleVersion = apperror.PreconditionFailed("stale_version", "The resource was changed since you last read it, fetch it again and retry")
+
+// Function updateVersioned writes columns to the row with the given id and bumps its version. With a non zero version
+// the write only applies while the row is still at that version, otherwise it fails with ErrStaleVersion.
+func updateVersioned(db *gorm.DB, model interface{}, id interface{}, columns map[string]interface{}, version uint64) error {
+	query := db.Model(model).Where("id = ?", id)
+	if version != 0 {
+		query = query.Where("version = ?", version)
+	}
+
+	columns["updated_at"] = time.Now()
+	columns["version"] = gorm.Expr("version + 1")
+	result := query.UpdateColumns(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if version != 0 {
+			return ErrStaleVersion
+		}
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Function selectColumns keeps the values of the named fields, fields are named as in JSON which matches the column names
+func selectColumns(values map[string]interface{}, fields []string) map[string]interface{} {
+	columns := map[string]interface{}{}
+	for _, field := range fields {
+		if value, ok := values[field]; ok {
+			columns[field] = value
+		}
+	}
+	return columns
+}
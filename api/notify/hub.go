@@ -0,0 +1,52 @@
+// Package notify records notifications and delivers them live to connected clients and by email.
+package notify
+
+import (
+	"sync"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+)
+
+// Hub fans notifications out to the live connections of each user
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[uint32]map[chan models.Notification]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: map[uint32]map[chan models.Notification]struct{}{}}
+}
+
+// Function Subscribe registers a live connection for a user, the returned function must be called once the connection closes
+func (hub *Hub) Subscribe(uid uint32) (<-chan models.Notification, func()) {
+	ch := make(chan models.Notification, 16)
+
+	hub.mu.Lock()
+	if hub.subscribers[uid] == nil {
+		hub.subscribers[uid] = map[chan models.Notification]struct{}{}
+	}
+	hub.subscribers[uid][ch] = struct{}{}
+	hub.mu.Unlock()
+
+	return ch, func() {
+		hub.mu.Lock()
+		delete(hub.subscribers[uid], ch)
+		if len(hub.subscribers[uid]) == 0 {
+			delete(hub.subscribers, uid)
+		}
+		hub.mu.Unlock()
+	}
+}
+
+// Function Publish sends a notification to every live connection of its recipient, slow connections miss it rather than block the sender
+func (hub *Hub) Publish(notification models.Notification) {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+
+	for ch := range hub.subscribers[notification.UserID] {
+		select {
+		case ch <- notification:
+		default:
+		}
+	}
+}
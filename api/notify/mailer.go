@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"strings"
+)
+
+// Mailer sends a plain text email
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// LogMailer writes emails to the log instead of sending them, it is used when no SMTP server is configured
+type LogMailer struct{}
+
+func (LogMailer) Send(to, subject, body string) error {
+	slog.Info("email", "to", to, "subject", subject, "body", body)
+	return nil
+}
+
+// SMTPMailer sends emails through an SMTP server
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+}
+
+func (mailer SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if mailer.User != "" {
+		auth = smtp.PlainAuth("", mailer.User, mailer.Password, mailer.Host)
+	}
+
+	message := strings.Join([]string{
+		"From: " + mailer.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(fmt.Sprintf("%s:%s", mailer.Host, mailer.Port), auth, mailer.From, []string{to}, []byte(message))
+}
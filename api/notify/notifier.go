@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"gorm.io/gorm"
+)
+
+// MaxFailures is how many deliveries in a row may fail before the notifier reports itself unhealthy
+const MaxFailures = 3
+
+// FailureCooldown is how long the notifier stays unhealthy after its last failed delivery. Without it an instance taken
+// out of rotation would get no traffic to deliver, and so no successful delivery to become healthy again.
+var FailureCooldown = 30 * time.Second
+
+// Notifier stores notifications, pushes them to live connections and emails the users who asked for it
+type Notifier struct {
+	DB     *gorm.DB
+	Hub    *Hub
+	Mailer Mailer
+
+	failures    atomic.Int32
+	lastFailure atomic.Int64 // unix nanoseconds
+}
+
+func NewNotifier(db *gorm.DB, mailer Mailer) *Notifier {
+	return &Notifier{DB: db, Hub: NewHub(), Mailer: mailer}
+}
+
+// Function Notify records an event for each recipient, the actor is never notified of their own actions.
+// Delivery happens in the background so the request that caused the event is not slowed down.
+func (notifier *Notifier) Notify(kind string, actorID uint32, recipients []uint32, postID, commentID *uint64) {
+	if notifier == nil {
+		return
+	}
+
+	notifications := []models.Notification{}
+	seen := map[uint32]bool{actorID: true}
+	for _, uid := range recipients {
+		if seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		notifications = append(notifications, models.Notification{UserID: uid, Type: kind, ActorID: actorID, PostID: postID, CommentID: commentID})
+	}
+	if len(notifications) == 0 {
+		return
+	}
+
+	go notifier.deliver(kind, notifications)
+}
+
+func (notifier *Notifier) deliver(kind string, notifications []models.Notification) {
+	err := models.SaveNotifications(notifier.DB, notifications)
+	if err != nil {
+		notifier.failed()
+		slog.Error("cannot save notifications", "error", err.Error())
+		return
+	}
+	notifier.failures.Store(0)
+
+	uids := make([]uint32, len(notifications))
+	for i, notification := range notifications {
+		notifier.Hub.Publish(notification)
+		uids[i] = notification.UserID
+	}
+
+	if notifier.Mailer == nil {
+		return
+	}
+	recipients, err := models.FindEmailRecipients(notifier.DB, kind, uids)
+	if err != nil {
+		slog.Error("cannot find email recipients", "error", err.Error())
+		return
+	}
+	subject, body := emailFor(notifications[0])
+	for _, user := range recipients {
+		err = notifier.Mailer.Send(user.Email, subject, body)
+		if err != nil {
+			slog.Error("cannot send a notification email", "user_id", user.ID, "error", err.Error())
+		}
+	}
+}
+
+// Function NotifyFollowers records an event for every follower of the actor, the followers are looked up in the background
+func (notifier *Notifier) NotifyFollowers(kind string, actorID uint32, postID *uint64) {
+	if notifier == nil {
+		return
+	}
+
+	go func() {
+		followers, err := models.FindFollowerIDs(notifier.DB, actorID)
+		if err != nil {
+			slog.Error("cannot find followers", "error", err.Error())
+			return
+		}
+		notifier.Notify(kind, actorID, followers, postID, nil)
+	}()
+}
+
+// Function failed counts a delivery that could not be saved
+func (notifier *Notifier) failed() {
+	notifier.lastFailure.Store(time.Now().UnixNano())
+	notifier.failures.Add(1)
+}
+
+// Function Healthy fails once several deliveries in a row could not be saved, until a delivery succeeds or no delivery
+// failed for FailureCooldown
+func (notifier *Notifier) Healthy() error {
+	if notifier == nil {
+		return nil
+	}
+	failures := notifier.failures.Load()
+	if failures < MaxFailures {
+		return nil
+	}
+	if time.Since(time.Unix(0, notifier.lastFailure.Load())) >= FailureCooldown {
+		return nil
+	}
+	return fmt.Errorf("the last %d notification deliveries failed", failures)
+}
+
+func emailFor(notification models.Notification) (string, string) {
+	actor := notification.Actor.UserName
+	switch notification.Type {
+	case models.NotificationComment:
+		return "New comment on your post", fmt.Sprintf("%s commented on your post.", actor)
+	case models.NotificationReply:
+		return "New reply to your comment", fmt.Sprintf("%s replied to your comment.", actor)
+	case models.NotificationFollow:
+		return "You have a new follower", fmt.Sprintf("%s started following you.", actor)
+	case models.NotificationPostPublished:
+		return "New post from " + actor, fmt.Sprintf("%s published a new post.", actor)
+	default:
+		return "New notification", fmt.Sprintf("%s did something that concerns you.", actor)
+	}
+}
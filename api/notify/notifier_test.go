@@ -0,0 +1,42 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthyRecoversAfterCooldown(t *testing.T) {
+	cooldown := FailureCooldown
+	t.Cleanup(func() { FailureCooldown = cooldown })
+	FailureCooldown = 50 * time.Millisecond
+
+	notifier := &Notifier{}
+	for i := 0; i < MaxFailures-1; i++ {
+		notifier.failed()
+	}
+	if err := notifier.Healthy(); err != nil {
+		t.Fatalf("unhealthy after %d failures: %v", MaxFailures-1, err)
+	}
+
+	notifier.failed()
+	if err := notifier.Healthy(); err == nil {
+		t.Fatalf("healthy after %d failures in a row", MaxFailures)
+	}
+
+	time.Sleep(FailureCooldown)
+	if err := notifier.Healthy(); err != nil {
+		t.Fatalf("still unhealthy once no delivery failed for the cooldown: %v", err)
+	}
+
+	notifier.failed()
+	if err := notifier.Healthy(); err == nil {
+		t.Fatal("healthy right after another failure")
+	}
+}
+
+func TestNilNotifierIsHealthy(t *testing.T) {
+	var notifier *Notifier
+	if err := notifier.Healthy(); err != nil {
+		t.Fatalf("a server without notifier is unhealthy: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+// Package openapi builds the OpenAPI 3.1 description of the API from the routes registered on the router.
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/gorilla/mux"
+)
+
+// Operation documents a single route, the path and method come from the router itself
+type Operation struct {
+	Summary     string
+	Tag         string
+	Auth        bool                   // the route requires a bearer token
+	Request     interface{}            // example value of the request body, nil when there is none
+	Requests    map[string]interface{} // example request bodies by content type, for routes that accept several formats
+	Response    interface{}            // example value of the response body, nil when there is none
+	Status      int                    // status of a successful response, 200 when left empty
+	Query       []string               // names of the accepted query parameters
+	Idempotent  bool                   // the route accepts an Idempotency-Key header
+	ContentType string                 // content type of the response, application/json when left empty
+}
+
+// Document is an OpenAPI document being built
+type Document struct {
+	schemas map[string]interface{}
+}
+
+var pathParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// versionPrefix matches the version a path starts with, e.g. /v1
+var versionPrefix = regexp.MustCompile(`^/v[0-9]+(/|$)`)
+
+// Function Key is how an operation is looked up for a route, e.g. "GET /posts/{id}"
+func Key(method, path string) string {
+	return method + " " + path
+}
+
+// Function Build walks the router and documents every route with its operation, it also returns the routes that have no operation.
+// Operations are keyed without the version prefix, "GET /posts/{id}" also documents GET /v1/posts/{id}, and the routes
+// of deprecated, when it is not nil, are marked deprecated.
+func Build(router *mux.Router, title, version string, operations map[string]Operation, deprecated *mux.Router) (map[string]interface{}, []string) {
+	doc := &Document{schemas: map[string]interface{}{}}
+	errorSchema := doc.Schema(responses.Problem{})
+
+	paths := map[string]map[string]interface{}{}
+	missing := []string{}
+
+	isDeprecated := map[string]bool{}
+	if deprecated != nil {
+		walk(deprecated, func(method, path string) {
+			isDeprecated[Key(method, path)] = true
+		})
+	}
+
+	walk(router, func(method, path string) {
+		operation, ok := operations[Key(method, path)]
+		if !ok {
+			operation, ok = operations[Key(method, "/"+versionPrefix.ReplaceAllString(path, ""))]
+		}
+		if !ok {
+			missing = append(missing, Key(method, path))
+			return
+		}
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		op := doc.operation(path, operation, errorSchema)
+		if isDeprecated[Key(method, path)] {
+			op["deprecated"] = true
+		}
+		paths[path][strings.ToLower(method)] = op
+	})
+
+	sort.Strings(missing)
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": doc.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}, missing
+}
+
+// Function walk calls fn with the method and path, its parameters written as {name}, of every route of router
+func walk(router *mux.Router, fn func(method, path string)) {
+	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		path := pathParam.ReplaceAllString(template, "{$1}")
+		for _, method := range methods {
+			fn(method, path)
+		}
+		return nil
+	})
+}
+
+func (doc *Document) operation(path string, operation Operation, errorSchema map[string]interface{}) map[string]interface{} {
+	status := operation.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	contentType := operation.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	parameters := []interface{}{}
+	for _, match := range pathParam.FindAllStringSubmatch(path, -1) {
+		parameters = append(parameters, map[string]interface{}{
+			"name": match[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, name := range operation.Query {
+		parameters = append(parameters, map[string]interface{}{
+			"name": name, "in": "query", "required": false, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+
+	if operation.Idempotent {
+		parameters = append(parameters, map[string]interface{}{
+			"name": "Idempotency-Key", "in": "header", "required": false, "schema": map[string]interface{}{"type": "string", "maxLength": 255},
+			"description": "Retries with the same key get the first response again instead of repeating the request",
+		})
+	}
+
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if operation.Response != nil && status != http.StatusNoContent {
+		schema := map[string]interface{}{"schema": doc.Schema(operation.Response)}
+		content := map[string]interface{}{contentType: schema}
+		// Encoded responses can be negotiated in any format of the responses package
+		if contentType == "application/json" {
+			for _, encoder := range responses.Encoders {
+				content[encoder.MediaType] = schema
+			}
+		}
+		success["content"] = content
+	}
+	failureContent := map[string]interface{}{}
+	for _, encoder := range responses.Encoders {
+		failureContent[encoder.ProblemType] = map[string]interface{}{"schema": errorSchema}
+	}
+	failure := map[string]interface{}{
+		"description": "Error",
+		"content":     failureContent,
+	}
+
+	op := map[string]interface{}{
+		"summary":    operation.Summary,
+		"parameters": parameters,
+		"responses": map[string]interface{}{
+			strconv.Itoa(status): success,
+			"default":            failure,
+		},
+	}
+	if operation.Tag != "" {
+		op["tags"] = []string{operation.Tag}
+	}
+	if operation.Auth {
+		op["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	}
+	requests := operation.Requests
+	if operation.Request != nil {
+		requests = map[string]interface{}{"application/json": operation.Request}
+	}
+	if len(requests) > 0 {
+		content := map[string]interface{}{}
+		for contentType, request := range requests {
+			content[contentType] = map[string]interface{}{"schema": doc.Schema(request)}
+		}
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  content,
+		}
+	}
+	return op
+}
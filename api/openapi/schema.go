@@ -0,0 +1,192 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// rawType is raw JSON, which can hold any value
+var rawType = reflect.TypeOf(json.RawMessage{})
+
+var packagePath = regexp.MustCompile(`[\w./-]*\.`)
+
+// Function Schema describes a Go value as a JSON Schema, following its json tags the same way encoding/json does.
+// Named structs are added to the components and referenced, so recursive types such as threaded comments terminate.
+func (doc *Document) Schema(value interface{}) map[string]interface{} {
+	if value == nil {
+		return map[string]interface{}{}
+	}
+	return doc.schemaOf(reflect.TypeOf(value))
+}
+
+func (doc *Document) schemaOf(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		schema := doc.schemaOf(t.Elem())
+		if kind, ok := schema["type"].(string); ok {
+			schema["type"] = []string{kind, "null"}
+		}
+		return schema
+	}
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if t == rawType {
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": doc.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": doc.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return doc.objectOf(t)
+		}
+		name := schemaName(t)
+		if _, ok := doc.schemas[name]; !ok {
+			doc.schemas[name] = map[string]interface{}{} // placeholder so recursion stops here
+			doc.schemas[name] = doc.objectOf(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// Function schemaName turns a type name into a component name, instances of generic types such as page[models.Post] become PagePost
+func schemaName(t reflect.Type) string {
+	name := packagePath.ReplaceAllString(t.Name(), "")
+	name = strings.NewReplacer("[", "", "]", "", ",", "", "*", "").Replace(name)
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func (doc *Document) objectOf(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		// Fields of embedded structs are promoted like encoding/json does
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			embedded := doc.objectOf(field.Type)
+			for name, schema := range embedded["properties"].(map[string]interface{}) {
+				if _, ok := properties[name]; !ok {
+					properties[name] = schema
+				}
+			}
+			continue
+		}
+
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+		}
+		schema := doc.schemaOf(field.Type)
+		rules := validation.Rules(field.Tag.Get("validate"))
+		if _, ok := rules["required"]; ok {
+			required = append(required, name)
+		}
+		constrain(schema, rules)
+		properties[name] = schema
+	}
+
+	object := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	return object
+}
+
+// Function constrain adds the limits declared in validate tags to a property schema
+func constrain(schema map[string]interface{}, rules map[string]string) {
+	kind := schema["type"]
+	if kinds, ok := kind.([]string); ok {
+		kind = kinds[0]
+	}
+
+	for rule, arg := range rules {
+		n, err := strconv.Atoi(arg)
+		switch {
+		case rule == "email":
+			schema["format"] = "email"
+		case rule == "oneof":
+			schema["enum"] = strings.Split(arg, "|")
+		case err != nil:
+		case rule == "min" && kind == "string":
+			schema["minLength"] = n
+		case rule == "max" && kind == "string":
+			schema["maxLength"] = n
+		case rule == "min" && kind == "array":
+			schema["minItems"] = n
+		case rule == "max" && kind == "array":
+			schema["maxItems"] = n
+		case rule == "min":
+			schema["minimum"] = n
+		case rule == "max":
+			schema["maximum"] = n
+		}
+	}
+}
+
+// Function Properties returns the JSON names of the properties of every object a value can contain, at any depth.
+// It lets callers check what a documented body can ever expose, e.g. that no response has a password.
+func Properties(value interface{}) []string {
+	doc := &Document{schemas: map[string]interface{}{}}
+	names := map[string]bool{}
+	collect(doc.Schema(value), names)
+	for _, schema := range doc.schemas {
+		collect(schema, names)
+	}
+
+	properties := make([]string, 0, len(names))
+	for name := range names {
+		properties = append(properties, name)
+	}
+	sort.Strings(properties)
+	return properties
+}
+
+func collect(schema interface{}, names map[string]bool) {
+	object, ok := schema.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if properties, ok := object["properties"].(map[string]interface{}); ok {
+		for name, property := range properties {
+			names[name] = true
+			collect(property, names)
+		}
+	}
+	collect(object["items"], names)
+	collect(object["additionalProperties"], names)
+}
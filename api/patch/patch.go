@@ -0,0 +1,125 @@
+// Package patch applies partial updates to a resource. Clients send either a JSON Merge Patch (RFC 7396), an object
+// holding the fields to change where null removes a field, or a JSON Patch (RFC 6902), a list of operations.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// Media types of the supported patch documents
+const (
+	MergePatch = "application/merge-patch+json"
+	JSONPatch  = "application/json-patch+json"
+)
+
+// Accepted lists the supported media types as the Accept-Patch header expects them
+var Accepted = MergePatch + ", " + JSONPatch
+
+// Operation is one step of a JSON Patch, it only exists to document the format
+type Operation struct {
+	Op    string      `json:"op" validate:"required,oneof=add|remove|replace|move|copy|test"`
+	Path  string      `json:"path" validate:"required"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Function Apply patches dst, a pointer to a struct holding the current values of the fields a client may change.
+// The patch is applied to dst in its JSON form, so paths and names are the JSON ones, and the result is decoded back
+// strictly: fields dst does not have are rejected. It returns the JSON names of the fields whose value changed.
+func Apply(contentType string, body []byte, dst interface{}) ([]string, error) {
+	original, err := json.Marshal(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := apply(contentType, original, body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := reflect.New(reflect.TypeOf(dst).Elem())
+	err = validation.Decode(patched, result.Interface())
+	if err != nil {
+		return nil, apperror.Validation("invalid_patch_result", "The patched resource is not valid: "+err.Error())
+	}
+
+	changed, err := changedFields(original, patched)
+	if err != nil {
+		return nil, err
+	}
+	reflect.ValueOf(dst).Elem().Set(result.Elem())
+	return changed, nil
+}
+
+func apply(contentType string, document, body []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+
+	switch strings.ToLower(mediaType) {
+	case MergePatch:
+		if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+			return nil, apperror.BadRequest("invalid_patch", "A merge patch must be a JSON object")
+		}
+		patched, err := jsonpatch.MergePatch(document, body)
+		if err != nil {
+			return nil, apperror.BadRequest("invalid_patch", "The merge patch is not valid JSON")
+		}
+		return patched, nil
+
+	case JSONPatch:
+		operations, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			return nil, apperror.BadRequest("invalid_patch", "The JSON patch is not a list of operations")
+		}
+		patched, err := operations.Apply(document)
+		switch {
+		case errors.Is(err, jsonpatch.ErrTestFailed):
+			return nil, apperror.Conflict("patch_test_failed", err.Error())
+		case err != nil:
+			return nil, apperror.Validation("patch_not_applicable", err.Error())
+		}
+		return patched, nil
+
+	default:
+		return nil, apperror.UnsupportedMediaType("unsupported_patch_type", "The Content-Type must be one of "+Accepted)
+	}
+}
+
+// Function changedFields compares two JSON objects and returns the names of the top level fields that differ
+func changedFields(before, after []byte) ([]string, error) {
+	var previous, current map[string]interface{}
+	err := json.Unmarshal(before, &previous)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(after, &current)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := []string{}
+	for name, value := range current {
+		if !reflect.DeepEqual(previous[name], value) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
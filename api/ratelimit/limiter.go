@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/logging"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/responses"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+)
+
+// Limiter applies named policies to handlers, all of them sharing one store
+type Limiter struct {
+	Store    Store
+	Policies map[string]Policy
+	Proxies  TrustedProxies
+}
+
+func NewLimiter(store Store, proxies TrustedProxies, policies ...Policy) *Limiter {
+	limiter := &Limiter{Store: store, Policies: map[string]Policy{}, Proxies: proxies}
+	for _, policy := range policies {
+		limiter.Policies[policy.Name] = policy
+	}
+	return limiter
+}
+
+// Function Limit wraps a handler with the named policy. The handler is returned as it is when the limiter is nil
+// or the policy is not configured, so limits can be switched off per route.
+func (limiter *Limiter) Limit(name string, next http.HandlerFunc) http.HandlerFunc {
+	if limiter == nil {
+		return next
+	}
+	policy, ok := limiter.Policies[name]
+	if !ok {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		decision, ok := limiter.take(policy, r)
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Period.Seconds())))
+		header.Set("RateLimit-Limit", strconv.Itoa(policy.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+
+		if !decision.Allowed {
+			header.Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+			responses.ERROR(w, http.StatusTooManyRequests, rateLimited)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Function Allow counts a request against the named policy for operations that share a route with others, like the
+// mutations of a GraphQL request. It returns an error once the limit is reached and nil when the limiter is nil, the
+// policy is not configured or the store failed.
+func (limiter *Limiter) Allow(name string, r *http.Request) error {
+	if limiter == nil {
+		return nil
+	}
+	policy, ok := limiter.Policies[name]
+	if !ok {
+		return nil
+	}
+
+	decision, ok := limiter.take(policy, r)
+	if ok && !decision.Allowed {
+		return rateLimited
+	}
+	return nil
+}
+
+var rateLimited = apperror.New(http.StatusTooManyRequests, "rate_limited", "Too many requests, retry later")
+
+// Function take takes a token for the request from the bucket of the policy, ok is false when the store failed
+func (limiter *Limiter) take(policy Policy, r *http.Request) (Decision, bool) {
+	decision, err := limiter.Store.Take(r.Context(), policy, limiter.key(policy, r), time.Now())
+	if err != nil {
+		// A broken store must not take the API down with it
+		logging.FromContext(r.Context()).WarnContext(r.Context(), "rate limit store failed", "policy", policy.Name, "error", err.Error())
+		return Decision{}, false
+	}
+	return decision, true
+}
+
+// Function key identifies who a request is counted against: the user behind a valid token for user policies,
+// and the client address otherwise
+func (limiter *Limiter) key(policy Policy, r *http.Request) string {
+	if policy.Key == KeyUser && auth.ExtractToken(r) != "" {
+		if uid, err := auth.ExtractTokenID(r); err == nil && uid != 0 {
+			return "user:" + strconv.FormatUint(uint64(uid), 10)
+		}
+	}
+	return "ip:" + limiter.Proxies.ClientIP(r)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the buckets of a single instance in memory
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweptAt time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}}
+}
+
+func (store *MemoryStore) Take(ctx context.Context, policy Policy, key string, now time.Time) (Decision, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.sweep(now)
+
+	rate := policy.Rate()
+	capacity := float64(policy.Burst)
+	id := policy.Name + ":" + key
+
+	b, ok := store.buckets[id]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		store.buckets[id] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	decision := Decision{}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+
+	decision.Remaining = int(b.tokens)
+	decision.Reset = seconds((capacity - b.tokens) / rate)
+	b.full = now.Add(decision.Reset)
+	return decision, nil
+}
+
+// Function sweep forgets the buckets that have refilled, at most once a minute, so memory stays bounded by active clients
+func (store *MemoryStore) sweep(now time.Time) {
+	if now.Sub(store.sweptAt) < time.Minute {
+		return
+	}
+	store.sweptAt = now
+	for id, b := range store.buckets {
+		if !now.Before(b.full) {
+			delete(store.buckets, id)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,66 @@
+// Package ratelimit limits how often clients may call a route with token buckets. Every policy has its own buckets,
+// keyed by client IP or by the authenticated user, and the buckets live in a Store so instances can share them.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keys a policy can count requests by
+const (
+	KeyIP   = "ip"
+	KeyUser = "user"
+)
+
+// Policy lets Limit requests through per Period with bursts of up to Burst requests, counted by Key
+type Policy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+	Burst  int
+	Key    string
+}
+
+// Function Rate returns how many tokens the bucket gains every second
+func (policy Policy) Rate() float64 {
+	return float64(policy.Limit) / policy.Period.Seconds()
+}
+
+// Function ParsePolicy reads a policy written as limit/period, e.g. 10/1m, the burst is the limit
+func ParsePolicy(name, key, spec string) (Policy, error) {
+	limit, period, ok := strings.Cut(strings.TrimSpace(spec), "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("rate limit %s: %q is not limit/period", name, spec)
+	}
+
+	n, err := strconv.Atoi(limit)
+	if err != nil || n <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %s: %q is not a positive limit", name, limit)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %s: %q is not a positive period", name, period)
+	}
+	return Policy{Name: name, Limit: n, Period: d, Burst: n, Key: key}, nil
+}
+
+// Decision is what a store decided about one request
+type Decision struct {
+	Allowed bool
+	// Remaining is how many requests can be made right away
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, it is zero when this one was
+	RetryAfter time.Duration
+}
+
+// Store holds the buckets. A shared store, e.g. one backed by Redis, must take the token atomically
+// so that instances sharing it never let more requests through than the policy allows.
+type Store interface {
+	Take(ctx context.Context, policy Policy, key string, now time.Time) (Decision, error)
+}
@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+)
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("login", KeyIP, " 10/1m ")
+	if err != nil {
+		t.Fatalf("ParsePolicy: %v", err)
+	}
+	if policy != (Policy{Name: "login", Limit: 10, Period: time.Minute, Burst: 10, Key: KeyIP}) {
+		t.Errorf("got %+v", policy)
+	}
+
+	for _, spec := range []string{"10", "0/1m", "-1/1m", "ten/1m", "10/soon", "10/0s"} {
+		if _, err := ParsePolicy("login", KeyIP, spec); err == nil {
+			t.Errorf("%q was accepted", spec)
+		}
+	}
+}
+
+func TestMemoryStoreRefills(t *testing.T) {
+	store := NewMemoryStore()
+	policy := Policy{Name: "login", Limit: 2, Period: time.Minute, Burst: 2, Key: KeyIP}
+	now := time.Now()
+
+	for i, want := range []int{1, 0} {
+		decision, _ := store.Take(context.Background(), policy, "ip:1", now)
+		if !decision.Allowed || decision.Remaining != want {
+			t.Fatalf("request %d: got %+v, want allowed with %d remaining", i+1, decision, want)
+		}
+	}
+
+	decision, _ := store.Take(context.Background(), policy, "ip:1", now)
+	if decision.Allowed || decision.RetryAfter != 30*time.Second || decision.Reset != time.Minute {
+		t.Fatalf("once the bucket is empty: got %+v, want refused, retry after 30s and full after 1m", decision)
+	}
+
+	decision, _ = store.Take(context.Background(), policy, "ip:2", now)
+	if !decision.Allowed {
+		t.Fatalf("another client shares the bucket: %+v", decision)
+	}
+
+	decision, _ = store.Take(context.Background(), policy, "ip:1", now.Add(30*time.Second))
+	if !decision.Allowed || decision.Remaining != 0 {
+		t.Fatalf("a token is back after 30s: got %+v", decision)
+	}
+}
+
+// Function limitedHandler serves ok behind the named policy of limiter
+func limitedHandler(limiter *Limiter, name string) http.HandlerFunc {
+	return limiter.Limit(name, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// Function request sends a request from the address to handler, with a bearer token when token is not empty
+func request(handler http.HandlerFunc, addr, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/v1/login", nil)
+	r.RemoteAddr = addr + ":4242"
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestLimitRefusesOnceTheBucketIsEmpty(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore(), nil, Policy{Name: "login", Limit: 2, Period: time.Minute, Burst: 2, Key: KeyIP})
+	handler := limitedHandler(limiter, "login")
+
+	for i, remaining := range []string{"1", "0"} {
+		w := request(handler, "192.0.2.1", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: got %d, want 200", i+1, w.Code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != remaining || w.Header().Get("RateLimit-Policy") != "2;w=60" {
+			t.Errorf("request %d: got headers %v", i+1, w.Header())
+		}
+	}
+
+	w := request(handler, "192.0.2.1", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "30" || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Reset") != "60" {
+		t.Errorf("got headers %v, want Retry-After 30, nothing remaining and a reset in 60s", w.Header())
+	}
+	if !strings.Contains(w.Body.String(), `"rate_limited"`) {
+		t.Errorf("the problem has no rate_limited code: %s", w.Body)
+	}
+
+	if w := request(handler, "192.0.2.2", ""); w.Code != http.StatusOK {
+		t.Errorf("another address was refused with %d", w.Code)
+	}
+}
+
+func TestLimitCountsUsersApart(t *testing.T) {
+	t.Setenv("API_SECRET", "test-secret")
+	limiter := NewLimiter(NewMemoryStore(), nil, Policy{Name: "create_post", Limit: 1, Period: time.Minute, Burst: 1, Key: KeyUser})
+	handler := limitedHandler(limiter, "create_post")
+	ada, _ := auth.CreateToken(1)
+	bob, _ := auth.CreateToken(2)
+
+	if w := request(handler, "192.0.2.1", ada); w.Code != http.StatusOK {
+		t.Fatalf("first post of user 1: got %d", w.Code)
+	}
+	if w := request(handler, "192.0.2.1", ada); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second post of user 1: got %d, want 429", w.Code)
+	}
+	if w := request(handler, "192.0.2.1", bob); w.Code != http.StatusOK {
+		t.Fatalf("user 2 behind the same address: got %d, want 200", w.Code)
+	}
+}
+
+// failingStore is a store that is down
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, policy Policy, key string, now time.Time) (Decision, error) {
+	return Decision{}, errors.New("store is down")
+}
+
+func TestLimitLetsRequestsThroughWhenTheStoreFails(t *testing.T) {
+	limiter := NewLimiter(failingStore{}, nil, Policy{Name: "login", Limit: 1, Period: time.Minute, Burst: 1, Key: KeyIP})
+	handler := limitedHandler(limiter, "login")
+	for i := 0; i < 3; i++ {
+		if w := request(handler, "192.0.2.1", ""); w.Code != http.StatusOK {
+			t.Fatalf("request %d: got %d, want 200", i+1, w.Code)
+		}
+	}
+}
+
+func TestAllow(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore(), nil, Policy{Name: "create_post", Limit: 1, Period: time.Minute, Burst: 1, Key: KeyIP})
+	r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	if err := limiter.Allow("create_post", r); err != nil {
+		t.Fatalf("first mutation: %v", err)
+	}
+	if err := limiter.Allow("create_post", r); err == nil {
+		t.Fatal("second mutation was allowed")
+	}
+	if err := limiter.Allow("register", r); err != nil {
+		t.Fatalf("a policy that is not configured refused: %v", err)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.10")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+
+	for _, test := range []struct {
+		name      string
+		peer      string
+		forwarded string
+		want      string
+	}{
+		{name: "direct client", peer: "198.51.100.7", want: "198.51.100.7"},
+		{name: "client picking its address", peer: "198.51.100.7", forwarded: "203.0.113.1", want: "198.51.100.7"},
+		{name: "behind a trusted proxy", peer: "10.1.2.3", forwarded: "203.0.113.1", want: "203.0.113.1"},
+		{name: "behind two trusted proxies", peer: "10.1.2.3", forwarded: "203.0.113.1, 192.0.2.10", want: "203.0.113.1"},
+		{name: "spoofed hop before the client", peer: "10.1.2.3", forwarded: "198.51.100.1, 203.0.113.1", want: "203.0.113.1"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = test.peer + ":4242"
+			if test.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", test.forwarded)
+			}
+			if got := proxies.ClientIP(r); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
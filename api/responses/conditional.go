@@ -0,0 +1,106 @@
+package responses
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+)
+
+// Strong entity tags of versioned resources look like "v3-5f1c0e2ab94d7c61": the version of the stored resource,
+// which If-Match is checked against, and a hash of the representation, which changes with live counts and formats
+var versionETag = regexp.MustCompile(`^"v(\d+)-[0-9a-f]+"$`)
+
+// Function Versioned writes data like JSON with a strong ETag built from the resource version. GET and HEAD requests
+// that already hold this representation get an empty 304 instead. No Last-Modified is sent: representations carry live
+// counts that change without the resource being updated, only the ETag follows them.
+func Versioned(w http.ResponseWriter, r *http.Request, statusCode int, data interface{}, version uint64) {
+	conditional(w, r, statusCode, data, func(hash string) string {
+		return `"v` + strconv.FormatUint(version, 10) + "-" + hash + `"`
+	})
+}
+
+// Function List writes a collection like JSON with a weak ETag, built from the representation since collections have no version
+func List(w http.ResponseWriter, r *http.Request, statusCode int, data interface{}) {
+	conditional(w, r, statusCode, data, func(hash string) string {
+		return `W/"` + hash + `"`
+	})
+}
+
+func conditional(w http.ResponseWriter, r *http.Request, statusCode int, data interface{}, etagFor func(hash string) string) {
+	encoder := encoderOf(w)
+	body, err := encode(encoder, data)
+	if err != nil {
+		write(w, statusCode, encoder, encoder.MediaType, data)
+		return
+	}
+
+	sum := sha256.Sum256(append([]byte(encoder.MediaType+"\n"), body...))
+	etag := etagFor(hex.EncodeToString(sum[:8]))
+
+	header := w.Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", "private, no-cache")
+
+	if statusCode == http.StatusOK && (r.Method == http.MethodGet || r.Method == http.MethodHead) && notModified(r, etag) {
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Type", encoder.MediaType)
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// Function notModified evaluates If-None-Match with the weak comparison. If-Modified-Since is ignored since responses
+// have no Last-Modified.
+func notModified(r *http.Request, etag string) bool {
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" {
+		return false
+	}
+	if strings.TrimSpace(inm) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(inm, ",") {
+		if opaque(strings.TrimSpace(candidate)) == opaque(etag) {
+			return true
+		}
+	}
+	return false
+}
+
+func opaque(etag string) string {
+	return strings.TrimPrefix(etag, "W/")
+}
+
+// Function IfMatch returns the resource versions named by the If-Match header, nil when the request has none or
+// sends *. Only the version is compared, so a tag weakened by response compression still matches, tags this API
+// did not issue fail the precondition.
+func IfMatch(r *http.Request) ([]uint64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	versions := []uint64{}
+	for _, candidate := range strings.Split(header, ",") {
+		match := versionETag.FindStringSubmatch(opaque(strings.TrimSpace(candidate)))
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err == nil {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, apperror.PreconditionFailed("etag_mismatch", "If-Match does not name a current version of the resource")
+	}
+	return versions, nil
+}
@@ -0,0 +1,43 @@
+package responses
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVersionedIgnoresIfModifiedSince(t *testing.T) {
+	post := map[string]interface{}{"id": 1, "comment_count": 2}
+	w := httptest.NewRecorder()
+	Versioned(w, httptest.NewRequest(http.MethodGet, "/v1/posts/1", nil), http.StatusOK, post, 3)
+	if w.Header().Get("Last-Modified") != "" {
+		t.Errorf("Last-Modified is sent although the counts change without the post: %q", w.Header().Get("Last-Modified"))
+	}
+	etag := w.Header().Get("ETag")
+
+	// The post was not updated but a comment was added, a client polling with a date must get the new counts
+	post["comment_count"] = 3
+	r := httptest.NewRequest(http.MethodGet, "/v1/posts/1", nil)
+	r.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	Versioned(w, r, http.StatusOK, post, 3)
+	if w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Fatalf("If-Modified-Since: got %d with %d bytes, want 200 with the post", w.Code, w.Body.Len())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/v1/posts/1", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	Versioned(w, r, http.StatusOK, post, 3)
+	if w.Code != http.StatusOK {
+		t.Fatalf("If-None-Match with the tag of the old counts: got %d, want 200", w.Code)
+	}
+
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	Versioned(w, r, http.StatusOK, post, 3)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("If-None-Match with the current tag: got %d with %d bytes, want an empty 304", w.Code, w.Body.Len())
+	}
+}
@@ -1,27 +1,119 @@
+// Package responses writes every response of the API. Handlers follow one status code policy:
+//
+//	200 a resource was read or updated, or an idempotent action was applied
+//	201 a resource was created, its URL is in the Location header
+//	204 a resource was deleted or an action has nothing to return, the body is empty
+//	304 the client already holds the representation named in If-None-Match or newer than If-Modified-Since
+//	400 the path, query or body could not be parsed
+//	401 the token is missing or invalid
+//	403 the token is valid but the user may not do this, e.g. it is not their resource
+//	404 the resource does not exist
+//	409 the request conflicts with existing data, e.g. a username is taken
+//	412 If-Match does not name the current version, the resource changed since the client read it
+//	413 the body is larger than the configured limit
+//	415 the body is in a format the route does not accept, e.g. a PATCH that is not a merge patch or JSON patch
+//	422 the body was parsed but breaks the validation rules
+//	429 the client sent too many requests, Retry-After says when to try again
+//	500 something unexpected went wrong
 package responses
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
 )
 
+// Problem is an RFC 7807 problem details document
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail"`
+	Code      string            `json:"code"`
+	RequestID string            `json:"request_id,omitempty"`
+	TraceID   string            `json:"trace_id,omitempty"`
+	Errors    validation.Errors `json:"errors,omitempty"`
+}
+
+// Function JSON writes data in the format the client negotiated through Accept, JSON unless it asked for another one
 func JSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	encoder := encoderOf(w)
+	write(w, statusCode, encoder, encoder.MediaType, data)
+}
+
+func write(w http.ResponseWriter, statusCode int, encoder Encoder, contentType string, data interface{}) {
+	body, err := encode(encoder, data)
+	if err != nil {
+		slog.Error("cannot encode the response", "error", err.Error())
+		statusCode, contentType = http.StatusInternalServerError, JSONEncoder.ProblemType
+		body = []byte(`{"type":"/problems/internal_error","title":"Internal Server Error","status":500,"detail":"Something went wrong","code":"internal_error"}` + "\n")
+	}
+
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(statusCode)
-	err := json.NewEncoder(w).Encode(data)
+	_, err = w.Write(body)
 	if err != nil {
-		fmt.Println(w, "%s", err.Error())
+		slog.Debug("cannot write the response", "error", err.Error())
 	}
 }
 
+// Function NoContent writes a 204 response, which must not have a body
+func NoContent(w http.ResponseWriter) {
+	w.Header().Del("Content-Type")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Function ERROR writes err as a problem details response, application/problem+json unless the client negotiated another format.
+// Domain errors carry their own status and code, any other error is reported with statusCode and a code derived from it.
 func ERROR(w http.ResponseWriter, statusCode int, err error) {
-	if err != nil {
-		JSON(w, statusCode, struct {
-			Error string `json:"error"`
-		}{
-			Error: err.Error(),
-		})
-		return
+	if err == nil {
+		err = errors.New(http.StatusText(http.StatusBadRequest))
+		statusCode = http.StatusBadRequest
+	}
+
+	problem := Problem{
+		Status:    statusCode,
+		Detail:    err.Error(),
+		Code:      codeFor(statusCode),
+		RequestID: w.Header().Get("X-Request-ID"),
+		TraceID:   w.Header().Get("X-Trace-ID"),
+	}
+
+	var appErr *apperror.Error
+	var fields validation.Errors
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &appErr):
+		problem.Status = appErr.Status
+		problem.Code = appErr.Code
+		problem.Detail = appErr.Detail
+		problem.Errors = appErr.Fields
+	case errors.As(err, &tooLarge):
+		problem.Status = http.StatusRequestEntityTooLarge
+		problem.Code = "body_too_large"
+		problem.Detail = fmt.Sprintf("The request body must not be larger than %d bytes", tooLarge.Limit)
+	case errors.As(err, &fields):
+		problem.Status = http.StatusUnprocessableEntity
+		problem.Code = "validation_failed"
+		problem.Errors = fields
+	case statusCode >= http.StatusInternalServerError:
+		// Unexpected failures can carry database details that clients must not see
+		problem.Detail = "Something went wrong"
 	}
-	JSON(w, http.StatusBadRequest, nil)
+
+	problem.Title = http.StatusText(problem.Status)
+	problem.Type = "/problems/" + problem.Code
+
+	encoder := encoderOf(w)
+	write(w, problem.Status, encoder, encoder.ProblemType, problem)
+}
+
+// Function codeFor derives a code from a status for errors that do not carry one, e.g. 404 becomes not_found
+func codeFor(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
 }
@@ -0,0 +1,194 @@
+package responses
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Encoder writes response bodies in one media type. Every encoder names fields after their json tags,
+// so a response has the same shape whatever format it is sent in.
+type Encoder struct {
+	MediaType   string
+	ProblemType string
+	Encode      func(w io.Writer, v interface{}) error
+}
+
+var cborMode, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+
+var (
+	JSONEncoder = Encoder{
+		MediaType:   "application/json",
+		ProblemType: "application/problem+json",
+		Encode: func(w io.Writer, v interface{}) error {
+			return json.NewEncoder(w).Encode(v)
+		},
+	}
+	MessagePackEncoder = Encoder{
+		MediaType:   "application/msgpack",
+		ProblemType: "application/msgpack",
+		Encode: func(w io.Writer, v interface{}) error {
+			encoder := msgpack.NewEncoder(w)
+			encoder.SetCustomStructTag("json")
+			return encoder.Encode(v)
+		},
+	}
+	CBOREncoder = Encoder{
+		MediaType:   "application/cbor",
+		ProblemType: "application/cbor",
+		Encode: func(w io.Writer, v interface{}) error {
+			return cborMode.NewEncoder(w).Encode(v)
+		},
+	}
+)
+
+// Encoders are the formats clients can ask for in Accept, the first one is the default
+var Encoders = []Encoder{JSONEncoder, MessagePackEncoder, CBOREncoder}
+
+// aliases are other media types clients use for the same formats
+var aliases = map[string]string{
+	"application/x-msgpack":    MessagePackEncoder.MediaType,
+	"application/vnd.msgpack":  MessagePackEncoder.MediaType,
+	"application/problem+json": JSONEncoder.MediaType,
+}
+
+// Function Negotiate picks the encoder for an Accept header, falling back to JSON when nothing offered is acceptable
+func Negotiate(accept string) Encoder {
+	offers := make([]string, 0, len(Encoders)+len(aliases))
+	for _, encoder := range Encoders {
+		offers = append(offers, encoder.MediaType)
+	}
+	for alias := range aliases {
+		offers = append(offers, alias)
+	}
+	sort.Strings(offers[len(Encoders):])
+
+	preferred := Preferred(accept, offers)
+	if canonical, ok := aliases[preferred]; ok {
+		preferred = canonical
+	}
+	for _, encoder := range Encoders {
+		if encoder.MediaType == preferred {
+			return encoder
+		}
+	}
+	return Encoders[0]
+}
+
+// Function Preferred returns the offer the header, an Accept or Accept-Encoding value, rates highest.
+// Ties go to the earlier offer and an empty string means no offer is acceptable; wildcards such as */* and application/* match.
+func Preferred(header string, offers []string) string {
+	if strings.TrimSpace(header) == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+
+	type rated struct {
+		value       string
+		q           float64
+		specificity int
+	}
+	ranges := []rated{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(fields[0]))
+		if value == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			name, raw, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(name, "q") {
+				if parsed, err := strconv.ParseFloat(raw, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		specificity := 2
+		if value == "*" || value == "*/*" {
+			specificity = 0
+		} else if strings.HasSuffix(value, "/*") {
+			specificity = 1
+		}
+		ranges = append(ranges, rated{value: value, q: q, specificity: specificity})
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := -1.0, -1
+		for _, r := range ranges {
+			if r.specificity <= specificity || !matches(r.value, offer) {
+				continue
+			}
+			q, specificity = r.q, r.specificity
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+func matches(pattern, offer string) bool {
+	switch {
+	case pattern == "*" || pattern == "*/*":
+		return true
+	case strings.HasSuffix(pattern, "/*"):
+		return strings.HasPrefix(offer, strings.TrimSuffix(pattern, "*"))
+	default:
+		return pattern == offer
+	}
+}
+
+// negotiatedWriter carries the encoder chosen for a request down to JSON and ERROR
+type negotiatedWriter struct {
+	http.ResponseWriter
+	encoder Encoder
+}
+
+func (w *negotiatedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *negotiatedWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Function WithEncoder returns a writer whose responses are encoded with the encoder the Accept header asks for
+func WithEncoder(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	w.Header().Add("Vary", "Accept")
+	return &negotiatedWriter{ResponseWriter: w, encoder: Negotiate(r.Header.Get("Accept"))}
+}
+
+// Function encoderOf finds the encoder WithEncoder chose, looking through writers that wrap it, and defaults to JSON
+func encoderOf(w http.ResponseWriter) Encoder {
+	for w != nil {
+		if negotiated, ok := w.(*negotiatedWriter); ok {
+			return negotiated.encoder
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = unwrapper.Unwrap()
+	}
+	return JSONEncoder
+}
+
+// Function encode renders v before anything is written, so an encoding failure can still become an error response
+func encode(encoder Encoder, v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := encoder.Encode(&buf, v)
+	return buf.Bytes(), err
+}
@@ -0,0 +1,37 @@
+package responses
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestMessagePackKeepsZeroValues(t *testing.T) {
+	type counts struct {
+		Read     bool   `json:"read"`
+		Count    int64  `json:"count"`
+		Name     string `json:"name"`
+		Optional string `json:"optional,omitempty"`
+	}
+
+	body := bytes.Buffer{}
+	err := MessagePackEncoder.Encode(&body, counts{})
+	if err != nil {
+		t.Fatalf("cannot encode: %v", err)
+	}
+	decoded := map[string]interface{}{}
+	err = msgpack.Unmarshal(body.Bytes(), &decoded)
+	if err != nil {
+		t.Fatalf("cannot decode: %v", err)
+	}
+
+	for _, field := range []string{"read", "count", "name"} {
+		if _, ok := decoded[field]; !ok {
+			t.Errorf("%s was dropped from %v", field, decoded)
+		}
+	}
+	if _, ok := decoded["optional"]; ok {
+		t.Errorf("optional is omitempty in JSON and should be omitted too: %v", decoded)
+	}
+}
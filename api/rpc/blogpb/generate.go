@@ -0,0 +1,4 @@
+// Package blogpb holds the messages and service stubs generated from blog.proto, run go generate after changing it.
+package blogpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative blog.proto
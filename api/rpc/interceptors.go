@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/logging"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Function Interceptors returns what every unary call of the services goes through, outermost first: logging, turning
+// errors into statuses, recovering panics and authentication. Services return domain errors like the REST handlers do.
+func Interceptors() []grpc.UnaryServerInterceptor {
+	return []grpc.UnaryServerInterceptor{logCalls, statuses, recoverPanics, authenticateCalls}
+}
+
+// Function logCalls writes one log line per call once it has been served, like the logging middleware does for requests
+func logCalls(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	attrs := []any{
+		"method", info.FullMethod,
+		"code", code.String(),
+		"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	log := logging.FromContext(ctx)
+	switch code {
+	case codes.OK:
+		log.InfoContext(ctx, "call", attrs...)
+	case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss:
+		log.ErrorContext(ctx, "call", attrs...)
+	default:
+		log.WarnContext(ctx, "call", attrs...)
+	}
+	return resp, err
+}
+
+// Function statuses turns the domain errors of the services into statuses
+func statuses(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, Error(err)
+	}
+	return resp, nil
+}
+
+// Function recoverPanics turns a panicking service into an internal error and logs the panic with its stack, without it
+// a panic would take the whole server down
+func recoverPanics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		logging.FromContext(ctx).ErrorContext(ctx, "service panicked", "method", info.FullMethod, "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+		resp, err = nil, apperror.Internal("internal_error", "Something went wrong")
+	}()
+	return handler(ctx, req)
+}
+
+// Function authenticateCalls refuses calls with an invalid token, calls without one go on anonymously
+func authenticateCalls(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+)
+
+// DefaultPageSize and MaxPageSize size the pages of lists the way per_page sizes the pages of the REST API
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+)
+
+// Page is a page of a list: the Size first items after the item with the id After, from the start when After is 0.
+// Lists page on ids, so a page does not shift when items are added before it.
+type Page struct {
+	Size  int
+	After uint64
+}
+
+// Function PageOf reads the page a list call asks for
+func PageOf(size int32, token string) (Page, error) {
+	page := Page{Size: DefaultPageSize}
+	if size < 0 || size > MaxPageSize {
+		return Page{}, apperror.BadRequest("invalid_page", fmt.Sprintf("page_size must be between 0 and %d", MaxPageSize))
+	}
+	if size > 0 {
+		page.Size = int(size)
+	}
+	if token != "" {
+		after, err := decodePageToken(token)
+		if err != nil {
+			return Page{}, err
+		}
+		page.After = after
+	}
+	return page, nil
+}
+
+// Function Trim cuts the items found for a page, fetched with one item more than the page holds, down to the page and
+// returns the token of the next page, "" when there is none
+func Trim[T any](items []T, page Page, id func(T) uint64) ([]T, string) {
+	if len(items) <= page.Size {
+		return items, ""
+	}
+	items = items[:page.Size]
+	return items, encodePageToken(id(items[len(items)-1]))
+}
+
+// Page tokens are opaque to clients, they wrap the id of the last item of a page so they can change without breaking anyone
+const pageTokenPrefix = "page:"
+
+func encodePageToken(id uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pageTokenPrefix + strconv.FormatUint(id, 10)))
+}
+
+func decodePageToken(token string) (uint64, error) {
+	invalid := apperror.BadRequest("invalid_page_token", "The page token is not one this API handed out")
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(raw), pageTokenPrefix) {
+		return 0, invalid
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(string(raw), pageTokenPrefix), 10, 64)
+	if err != nil {
+		return 0, invalid
+	}
+	return id, nil
+}
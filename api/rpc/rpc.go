@@ -0,0 +1,128 @@
+// Package rpc serves the gRPC API next to the REST one. The services themselves live with the REST handlers in
+// controllers so both APIs share their checks, this package holds what any service needs: authentication, turning
+// domain errors into statuses and paging.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/auth"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/formaterror"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Domain names the errors of the API in the ErrorInfo detail of a status, its reason is the code of the REST problem
+const Domain = "blog.v1"
+
+// Function Error turns an error into the status a client gets, with the code and fields of the domain error in its
+// details. Errors that already are statuses pass through.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var appErr *apperror.Error
+	if !errors.As(formaterror.FormatError(err), &appErr) {
+		return status.Error(codes.Internal, "Something went wrong")
+	}
+
+	st := status.New(codeOf(appErr.Status), appErr.Detail)
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: appErr.Code, Domain: Domain}}
+	if len(appErr.Fields) > 0 {
+		violations := &errdetails.BadRequest{}
+		for _, field := range appErr.Fields {
+			violations.FieldViolations = append(violations.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: field.Field, Description: field.Message})
+		}
+		details = append(details, violations)
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// Function codeOf maps the HTTP status of a domain error to the gRPC code that means the same
+func codeOf(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusUnsupportedMediaType:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+type userKey struct{}
+
+// Function UserID returns the user behind the token of a call, calls that change data need one like the private REST
+// routes do
+func UserID(ctx context.Context) (uint32, error) {
+	uid, _ := ctx.Value(userKey{}).(uint32)
+	if uid == 0 {
+		return 0, apperror.Unauthorized("invalid_token", "Unauthorized")
+	}
+	return uid, nil
+}
+
+// Function token returns the bearer token in the authorization metadata of a call, or "" without one
+func token(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if scheme, token, ok := strings.Cut(value, " "); ok && strings.EqualFold(scheme, "bearer") {
+			return token
+		}
+	}
+	return ""
+}
+
+// Function HTTPRequest adapts a call to the *http.Request the helpers shared with the REST handlers read: it carries
+// the context of the call, its token in the Authorization header and the address of the client, so rate limits and
+// the view of the viewer work the same for both APIs
+func HTTPRequest(ctx context.Context) *http.Request {
+	r, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	if token := token(ctx); token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r
+}
+
+// Function authenticate verifies the token of a call, when it has one, with the JWT checks of the REST API
+func authenticate(ctx context.Context) (context.Context, error) {
+	token := token(ctx)
+	if token == "" {
+		return ctx, nil
+	}
+	uid, err := auth.TokenID(ctx, token)
+	if err != nil || uid == 0 {
+		return ctx, apperror.Unauthorized("invalid_token", "Unauthorized")
+	}
+	return context.WithValue(ctx, userKey{}, uid), nil
+}
@@ -1,7 +1,8 @@
 package seed
 
 import (
-	"log"
+	"log/slog"
+	"os"
 
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
 	"gorm.io/gorm"
@@ -12,6 +13,7 @@ var users = []models.User{
 		UserName: "Steven victor",
 		Email:    "steven@gmail.com",
 		Password: "password",
+		Role:     models.RoleAdmin,
 	},
 	models.User{
 		UserName: "Martin Luther",
@@ -31,27 +33,37 @@ var posts = []models.Post{
 	},
 }
 
+// Function Load seeds a new database with a couple of users and their posts, the first user is an admin. A database that already holds users is left
+// as it is, so restarts keep what users wrote, who they follow and what the spam filter learnt.
 func Load(db *gorm.DB) {
-
-	err := db.Debug().DropTablefExists(&models.Post{}, &models.User{}).Error
+	var count int64
+	err := db.Model(&models.User{}).Count(&count).Error
 	if err != nil {
-		log.Fatalf("cannot drop table: %v", err)
+		slog.Error("cannot count users", "error", err.Error())
+		os.Exit(1)
 	}
-	err = db.Debug().AutoMigrate(&models.User{}, &models.Post{}).Error
-	if err != nil {
-		log.Fatalf("cannot migrate table: %v", err)
+	if count > 0 {
+		return
 	}
 
-	for i := range users {
-		err = db.Debug().Model(&models.User{}).Create(&users[i]).Error
-		if err != nil {
-			log.Fatalf("cannot seed users table: %v", err)
-		}
-		posts[i].AuthorID = users[i].ID
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for i := range users {
+			_, err := users[i].SaveUser(tx)
+			if err != nil {
+				return err
+			}
+			posts[i].AuthorID = users[i].ID
 
-		err = db.Debug().Model(&models.Post{}).Create(&posts[i]).Error
-		if err != nil {
-			log.Fatalf("cannot seed posts table: %v", err)
+			err = tx.Model(&models.Post{}).Create(&posts[i]).Error
+			if err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("cannot seed the database", "error", err.Error())
+		os.Exit(1)
 	}
+	slog.Info("seeded the database", "users", len(users), "posts", len(posts))
 }
@@ -1,13 +1,25 @@
 package api
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/controllers"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/idempotency"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/logging"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/middlewares"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/notify"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/ratelimit"
 	"github.com/AbdulrahmanDaud10/fullstack-project/api/seed"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/spam"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/tracing"
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
 var server = controllers.Server{}
@@ -17,15 +29,164 @@ func Run() {
 	var err error
 	err = godotenv.Load()
 	if err != nil {
-		log.Fatalf("Error getting env, not comming through %v", err)
-	} else {
-		fmt.Println("We are getting the env values")
+		slog.Error("cannot load the env file", "error", err.Error())
+		os.Exit(1)
+	}
+
+	slog.SetDefault(logging.New(os.Stdout, os.Getenv("LOG_LEVEL")))
+	server.SQLLog = logging.NewGormLogger(os.Getenv("SQL_LOG_LEVEL"), os.Getenv("SQL_LOG_PARAMS") == "true")
+
+	shutdownTracing, err := tracing.Setup(context.Background(), os.Getenv("TRACE_EXPORTER"), os.Getenv("TRACE_FILE"))
+	if err != nil {
+		slog.Error("cannot set up tracing", "error", err.Error())
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
+	if depth, err := strconv.Atoi(os.Getenv("COMMENT_MAX_DEPTH")); err == nil && depth >= 0 {
+		models.MaxCommentDepth = depth
+	}
+	if policy := os.Getenv("COMMENT_MODERATION"); policy != "" {
+		models.ModerationPolicy = policy
+	}
+	if drain, err := time.ParseDuration(os.Getenv("SHUTDOWN_DRAIN")); err == nil && drain >= 0 {
+		controllers.DrainDelay = drain
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && timeout > 0 {
+		controllers.ShutdownTimeout = timeout
+	}
+
+	server.Limiter, err = rateLimiter()
+	if err != nil {
+		slog.Error("cannot configure rate limiting", "error", err.Error())
+		os.Exit(1)
+	}
+
+	idempotencyTTL, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL"))
+	if err != nil {
+		idempotencyTTL = idempotency.DefaultTTL
+	}
+	server.Idempotency = idempotency.NewGuard(idempotency.NewMemoryStore(), idempotencyTTL, server.Limiter.Proxies)
+
+	server.CORS = middlewares.CORSConfig{AllowCredentials: os.Getenv("CORS_ALLOW_CREDENTIALS") == "true"}
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			server.CORS.AllowedOrigins = append(server.CORS.AllowedOrigins, origin)
+		}
+	}
+	if maxAge, err := time.ParseDuration(os.Getenv("CORS_MAX_AGE")); err == nil {
+		server.CORS.MaxAge = maxAge
+	}
+	err = server.CORS.Validate()
+	if err != nil {
+		slog.Error("cannot configure CORS", "error", err.Error())
+		os.Exit(1)
+	}
+
+	server.Security = middlewares.SecurityConfig{ContentSecurityPolicy: os.Getenv("CONTENT_SECURITY_POLICY")}
+	if maxAge, err := time.ParseDuration(os.Getenv("HSTS_MAX_AGE")); err == nil {
+		server.Security.HSTSMaxAge = maxAge
+	}
+	if since, err := time.Parse(time.DateOnly, os.Getenv("LEGACY_DEPRECATED_SINCE")); err == nil {
+		server.Deprecation.Since = since
+	}
+	if sunset, err := time.Parse(time.DateOnly, os.Getenv("LEGACY_SUNSET")); err == nil {
+		server.Deprecation.Sunset = sunset
+	}
+	if limit, err := strconv.ParseInt(os.Getenv("MAX_BODY_BYTES"), 10, 64); err == nil {
+		server.MaxBodyBytes = limit
+	}
+	if minBytes, err := strconv.Atoi(os.Getenv("COMPRESSION_MIN_BYTES")); err == nil {
+		server.CompressionMinBytes = minBytes
+	}
+	if depth, err := strconv.Atoi(os.Getenv("GRAPHQL_MAX_DEPTH")); err == nil {
+		server.GraphQLLimits.MaxDepth = depth
+	}
+	if complexity, err := strconv.Atoi(os.Getenv("GRAPHQL_MAX_COMPLEXITY")); err == nil {
+		server.GraphQLLimits.MaxComplexity = complexity
+	}
+	server.GraphQLPlayground = os.Getenv("APP_ENV") == "development"
+	server.GRPCAddr = os.Getenv("GRPC_ADDR")
+	if operations, err := strconv.Atoi(os.Getenv("BATCH_MAX_OPERATIONS")); err == nil {
+		server.BatchMaxOperations = operations
 	}
 
 	server.IntializeDB(os.Getenv("DB_DRIVER"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_PORT"), os.Getenv("DB_HOST"), os.Getenv("DB_NAME"))
 
 	seed.Load(server.DB)
+	grantRoles(server.DB, models.RoleAdmin, os.Getenv("ADMIN_EMAILS"))
+	grantRoles(server.DB, models.RoleModerator, os.Getenv("MODERATOR_EMAILS"))
+
+	bayes, err := spam.NewBayes(server.DB)
+	if err != nil {
+		slog.Error("cannot load the spam classifier", "error", err.Error())
+		os.Exit(1)
+	}
+	maxLinks, err := strconv.Atoi(os.Getenv("SPAM_MAX_LINKS"))
+	if err != nil {
+		maxLinks = 3
+	}
+	server.Spam = spam.Combined{bayes, spam.LinkScorer{MaxLinks: maxLinks}, spam.NewBlocklistScorer(os.Getenv("SPAM_BLOCKLIST"))}
+
+	var mailer notify.Mailer = notify.LogMailer{}
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		mailer = notify.SMTPMailer{
+			Host:     host,
+			Port:     os.Getenv("SMTP_PORT"),
+			User:     os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	}
+	server.Notifier = notify.NewNotifier(server.DB, mailer)
 
 	server.Run(":8080")
 
 }
+
+// Function rateLimiter builds the rate limit policies from RATE_LIMIT_* variables, a policy left empty is switched off
+func rateLimiter() (*ratelimit.Limiter, error) {
+	proxies, err := ratelimit.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, err
+	}
+
+	policies := []ratelimit.Policy{}
+	for _, route := range []struct{ name, key, env string }{
+		{"login", ratelimit.KeyIP, "RATE_LIMIT_LOGIN"},
+		{"register", ratelimit.KeyIP, "RATE_LIMIT_REGISTER"},
+		{"create_post", ratelimit.KeyUser, "RATE_LIMIT_CREATE_POST"},
+	} {
+		spec := os.Getenv(route.env)
+		if spec == "" {
+			continue
+		}
+		policy, err := ratelimit.ParsePolicy(route.name, route.key, spec)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return ratelimit.NewLimiter(ratelimit.NewMemoryStore(), proxies, policies...), nil
+}
+
+// Function grantRoles gives the role to the users whose emails are listed, comma separated, in emails. Roles can only be
+// granted this way or by the seed, the API never lets users change their own role.
+func grantRoles(db *gorm.DB, role, emails string) {
+	list := []string{}
+	for _, email := range strings.Split(emails, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			list = append(list, email)
+		}
+	}
+	if len(list) == 0 {
+		return
+	}
+
+	granted, err := models.GrantRole(db, role, list)
+	if err != nil {
+		slog.Error("cannot grant roles", "role", role, "error", err.Error())
+		os.Exit(1)
+	}
+	slog.Info("granted roles", "role", role, "users", granted)
+}
@@ -0,0 +1,117 @@
+package spam
+
+import (
+	"math"
+	"sync"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/models"
+	"gorm.io/gorm"
+)
+
+// Bayes is a naive Bayesian classifier trained from the comments moderators approve or mark as spam.
+// The word counts are kept in memory and persisted to the spam_tokens table.
+type Bayes struct {
+	db *gorm.DB
+
+	mu        sync.RWMutex
+	spam      map[string]int64
+	ham       map[string]int64
+	spamDocs  int64
+	hamDocs   int64
+	spamWords int64
+	hamWords  int64
+}
+
+// Function NewBayes loads the classifier from what was learnt so far
+func NewBayes(db *gorm.DB) (*Bayes, error) {
+	bayes := &Bayes{db: db, spam: map[string]int64{}, ham: map[string]int64{}}
+
+	tokens, err := models.FindAllSpamTokens(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		if token.Token == models.SpamDocumentsToken {
+			bayes.spamDocs, bayes.hamDocs = token.Spam, token.Ham
+			continue
+		}
+		bayes.spam[token.Token] = token.Spam
+		bayes.ham[token.Token] = token.Ham
+		bayes.spamWords += token.Spam
+		bayes.hamWords += token.Ham
+	}
+
+	return bayes, nil
+}
+
+// Function Score returns the probability of the content being spam, 0.5 until it has seen both spam and legitimate comments
+func (bayes *Bayes) Score(content string) float64 {
+	bayes.mu.RLock()
+	defer bayes.mu.RUnlock()
+
+	if bayes.spamDocs == 0 || bayes.hamDocs == 0 {
+		return 0.5
+	}
+
+	vocabulary := float64(len(bayes.spam) + 1)
+	logSpam := math.Log(float64(bayes.spamDocs) / float64(bayes.spamDocs+bayes.hamDocs))
+	logHam := math.Log(float64(bayes.hamDocs) / float64(bayes.spamDocs+bayes.hamDocs))
+	for _, token := range Tokenize(content) {
+		logSpam += math.Log((float64(bayes.spam[token]) + 1) / (float64(bayes.spamWords) + vocabulary))
+		logHam += math.Log((float64(bayes.ham[token]) + 1) / (float64(bayes.hamWords) + vocabulary))
+	}
+
+	return 1 / (1 + math.Exp(logHam-logSpam))
+}
+
+// Function Train records a moderator decision on a comment
+func (bayes *Bayes) Train(content string, isSpam bool) error {
+	return bayes.learn(content, isSpam, 1)
+}
+
+// Function Forget takes back a decision Train recorded
+func (bayes *Bayes) Forget(content string, isSpam bool) error {
+	return bayes.learn(content, isSpam, -1)
+}
+
+// Function learn adds the words of the content to the spam or ham counts, sign is 1 to learn them and -1 to forget them
+func (bayes *Bayes) learn(content string, isSpam bool, sign int64) error {
+	counts := map[string]int64{}
+	for _, token := range Tokenize(content) {
+		counts[token] += sign
+	}
+
+	rows := []models.SpamToken{{Token: models.SpamDocumentsToken}}
+	for token, count := range counts {
+		rows = append(rows, models.SpamToken{Token: token})
+		if isSpam {
+			rows[len(rows)-1].Spam = count
+		} else {
+			rows[len(rows)-1].Ham = count
+		}
+	}
+	if isSpam {
+		rows[0].Spam = sign
+	} else {
+		rows[0].Ham = sign
+	}
+
+	err := models.IncrementSpamTokens(bayes.db, rows)
+	if err != nil {
+		return err
+	}
+
+	bayes.mu.Lock()
+	defer bayes.mu.Unlock()
+	for _, row := range rows[1:] {
+		// Both maps always hold the same words so either one gives the vocabulary size
+		bayes.spam[row.Token] += row.Spam
+		bayes.ham[row.Token] += row.Ham
+		bayes.spamWords += row.Spam
+		bayes.hamWords += row.Ham
+	}
+	bayes.spamDocs += rows[0].Spam
+	bayes.hamDocs += rows[0].Ham
+
+	return nil
+}
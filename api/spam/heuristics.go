@@ -0,0 +1,48 @@
+package spam
+
+import (
+	"math"
+	"strings"
+)
+
+// LinkScorer flags content that is mostly links, reaching a score of 1 at MaxLinks links
+type LinkScorer struct {
+	MaxLinks int
+}
+
+func (scorer LinkScorer) Score(content string) float64 {
+	if scorer.MaxLinks < 1 {
+		return 0
+	}
+
+	lower := strings.ToLower(content)
+	links := strings.Count(lower, "http://") + strings.Count(lower, "https://") + strings.Count(lower, "www.")
+	return math.Min(1, float64(links)/float64(scorer.MaxLinks))
+}
+
+// BlocklistScorer flags content containing any of the blocked words or phrases
+type BlocklistScorer struct {
+	Terms []string
+}
+
+// Function NewBlocklistScorer builds a blocklist from a comma separated list of terms
+func NewBlocklistScorer(list string) BlocklistScorer {
+	terms := []string{}
+	for _, term := range strings.Split(list, ",") {
+		term = strings.ToLower(strings.TrimSpace(term))
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return BlocklistScorer{Terms: terms}
+}
+
+func (scorer BlocklistScorer) Score(content string) float64 {
+	lower := strings.ToLower(content)
+	for _, term := range scorer.Terms {
+		if strings.Contains(lower, term) {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+// Package spam scores comments so obvious spam can be kept out of the moderation queue.
+// Everything runs locally, no external service is involved.
+package spam
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Threshold is the score from which a comment is treated as spam without waiting for a moderator
+var Threshold = 0.9
+
+// Scorer rates content between 0 (clearly legitimate) and 1 (clearly spam)
+type Scorer interface {
+	Score(content string) float64
+}
+
+// Trainer is implemented by scorers that learn from moderator decisions. Forget takes back a decision learnt before, so a
+// comment whose decision changes only ever counts once.
+type Trainer interface {
+	Train(content string, isSpam bool) error
+	Forget(content string, isSpam bool) error
+}
+
+// Combined scores content with every scorer and keeps the highest score, so any strong signal is enough to flag a comment
+type Combined []Scorer
+
+func (scorers Combined) Score(content string) float64 {
+	score := 0.0
+	for _, scorer := range scorers {
+		if s := scorer.Score(content); s > score {
+			score = s
+		}
+	}
+	return score
+}
+
+// Function Train forwards a moderator decision to every scorer that can learn from it
+func (scorers Combined) Train(content string, isSpam bool) error {
+	for _, scorer := range scorers {
+		if trainer, ok := scorer.(Trainer); ok {
+			if err := trainer.Train(content, isSpam); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (scorers Combined) Forget(content string, isSpam bool) error {
+	for _, scorer := range scorers {
+		if trainer, ok := scorer.(Trainer); ok {
+			if err := trainer.Forget(content, isSpam); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Function Tokenize splits content into the lowercased words used by the scorers
+func Tokenize(content string) []string {
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := words[:0]
+	for _, word := range words {
+		if len(word) > 1 && len(word) <= 64 {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
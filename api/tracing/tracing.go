@@ -0,0 +1,92 @@
+// Package tracing sets up OpenTelemetry tracing for the API. Spans start in the router middleware, continue through
+// token verification and end at the database, and are exported over OTLP or written as JSON for local use.
+package tracing
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters that Setup understands
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+const instrumentation = "github.com/AbdulrahmanDaud10/fullstack-project/api"
+
+// Function Tracer returns the tracer the API starts its spans with
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Function Setup installs the global tracer provider and the W3C trace context propagator. The exporter is one of
+// otlp, which reads the standard OTEL_EXPORTER_OTLP_* variables, stdout, file, which writes to path, or none.
+// The returned function flushes the spans still buffered and must be called before the process exits.
+func Setup(ctx context.Context, exporter, path string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var spanExporter sdktrace.SpanExporter
+	var closer io.Closer
+	var err error
+	switch strings.ToLower(strings.TrimSpace(exporter)) {
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		var file *os.File
+		file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		closer = file
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		// Without an exporter spans are still created, so trace ids keep showing up in logs and error responses
+		provider := sdktrace.NewTracerProvider()
+		otel.SetTracerProvider(provider)
+		return provider.Shutdown, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("blog-api")))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(spanExporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
+
+// Function TraceID returns the id of the trace ctx belongs to, or an empty string when it is not traced
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
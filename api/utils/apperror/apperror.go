@@ -0,0 +1,64 @@
+// Package apperror defines the domain errors handlers return, each one carries the HTTP status
+// it maps to and a stable machine-readable code clients can rely on.
+package apperror
+
+import (
+	"net/http"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
+)
+
+// Error is a domain error, Detail is meant for humans and Code for programs
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Fields validation.Errors
+}
+
+func (err *Error) Error() string {
+	return err.Detail
+}
+
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func BadRequest(code, detail string) *Error {
+	return New(http.StatusBadRequest, code, detail)
+}
+
+func Unauthorized(code, detail string) *Error {
+	return New(http.StatusUnauthorized, code, detail)
+}
+
+func Forbidden(code, detail string) *Error {
+	return New(http.StatusForbidden, code, detail)
+}
+
+func NotFound(code, detail string) *Error {
+	return New(http.StatusNotFound, code, detail)
+}
+
+func Conflict(code, detail string) *Error {
+	return New(http.StatusConflict, code, detail)
+}
+
+// Function PreconditionFailed reports a conditional request whose precondition, e.g. If-Match, does not hold
+func PreconditionFailed(code, detail string) *Error {
+	return New(http.StatusPreconditionFailed, code, detail)
+}
+
+// Function Validation reports invalid input, the fields say which part of the input is wrong
+func Validation(code, detail string, fields ...validation.FieldError) *Error {
+	return &Error{Status: http.StatusUnprocessableEntity, Code: code, Detail: detail, Fields: fields}
+}
+
+func Internal(code, detail string) *Error {
+	return New(http.StatusInternalServerError, code, detail)
+}
+
+// Function UnsupportedMediaType reports a body sent in a format the route does not accept
+func UnsupportedMediaType(code, detail string) *Error {
+	return New(http.StatusUnsupportedMediaType, code, detail)
+}
@@ -2,29 +2,84 @@ package formaterror
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/apperror"
+	"github.com/AbdulrahmanDaud10/fullstack-project/api/utils/validation"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	notNullViolation    = "23502"
+	stringTooLong       = "22001"
 )
 
-func FormatError(err string) error {
-	if strings.Contains(err, "user_name") {
-		return errors.New("Username is already taken")
+// Function FormatError turns an error from the database or the password check into a domain error.
+// Database errors are recognised from the driver error codes, unknown errors are hidden behind a generic internal error.
+func FormatError(err error) error {
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		return apperror.Validation("validation_failed", "The request is invalid", fields...)
 	}
 
-	if strings.Contains(err, "email") {
-		return errors.New("Email is already taken")
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.NotFound("not_found", "Record not found")
 	}
 
-	if strings.Contains(err, "title") {
-		return errors.New("Title is already taken")
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return apperror.Unauthorized("invalid_credentials", "Password is incorrect")
 	}
 
-	if strings.Contains(err, "hashedPassword") {
-		return errors.New("Password is incorrect")
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		field := constraintField(pgErr)
+		switch pgErr.Code {
+		case uniqueViolation:
+			detail := fmt.Sprintf("%s is already taken", humanize(field))
+			appErr := apperror.Conflict(field+"_taken", detail)
+			appErr.Fields = validation.Errors{{Field: field, Message: detail}}
+			return appErr
+		case foreignKeyViolation:
+			return apperror.Validation("invalid_reference", "The request refers to a record that does not exist")
+		case notNullViolation:
+			return apperror.Validation("validation_failed", "The request is invalid", validation.FieldError{Field: pgErr.ColumnName, Message: pgErr.ColumnName + " is required"})
+		case stringTooLong:
+			return apperror.Validation("validation_failed", "A value in the request is too long")
+		}
 	}
 
-	if strings.Contains(err, "email") {
-		return errors.New("Email is already taken")
+	return apperror.Internal("internal_error", "Something went wrong")
+}
+
+// Function constraintField recovers the column a constraint is on from its name,
+// Postgres names unique constraints <table>_<column>_key and gorm names indexes idx_<table>_<column>
+func constraintField(pgErr *pgconn.PgError) string {
+	if pgErr.ColumnName != "" {
+		return pgErr.ColumnName
 	}
 
-	return errors.New("Incorrrect details")
+	name := pgErr.ConstraintName
+	name = strings.TrimPrefix(name, "idx_")
+	name = strings.TrimPrefix(name, pgErr.TableName+"_")
+	name = strings.TrimSuffix(name, "_key")
+	if name == "" {
+		return "value"
+	}
+	return name
+}
+
+func humanize(field string) string {
+	words := strings.ReplaceAll(field, "_", " ")
+	return strings.ToUpper(words[:1]) + words[1:]
 }
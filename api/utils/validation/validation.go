@@ -0,0 +1,204 @@
+// Package validation checks request payloads against the rules declared in validate struct tags.
+//
+// Rules are separated by commas, e.g. `validate:"required,max=255"`:
+//
+//	required     the field must not be its zero value
+//	min=N, max=N length bounds for strings and slices, value bounds for numbers
+//	email        the string must be a valid email address
+//	oneof=a|b    the string must be one of the listed values
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/badoux/checkmail"
+)
+
+// FieldError is a rule a single field failed
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors lists every field that failed validation
+type Errors []FieldError
+
+func (errs Errors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Function Decode strictly unmarshals a JSON body, rejecting unknown fields and anything after the first JSON value
+func Decode(body []byte, dst interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("Request body is empty")
+		}
+		return err
+	}
+
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("Request body must contain a single JSON value")
+	}
+	return nil
+}
+
+// Function Struct validates the fields of a struct against their validate tags.
+// When field names are given only those fields, named as in JSON, are checked.
+func Struct(v interface{}, fields ...string) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	only := map[string]bool{}
+	for _, field := range fields {
+		only[field] = true
+	}
+
+	errs := Errors{}
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		rules := field.Tag.Get("validate")
+		if rules == "" {
+			continue
+		}
+
+		name := JSONName(field)
+		if len(only) > 0 && !only[name] {
+			continue
+		}
+
+		if message := check(value.Field(i), rules); message != "" {
+			errs = append(errs, FieldError{Field: name, Message: name + " " + message})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Function JSONName returns the name a struct field has in JSON
+func JSONName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// Function Rules parses a validate tag into its rules and their arguments
+func Rules(tag string) map[string]string {
+	rules := map[string]string{}
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(rule, "=")
+		rules[name] = arg
+	}
+	return rules
+}
+
+// Function check returns why the value breaks its rules, or an empty string when it is valid
+func check(value reflect.Value, tag string) string {
+	rules := Rules(tag)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			if _, ok := rules["required"]; ok {
+				return "is required"
+			}
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	if _, ok := rules["required"]; ok && value.IsZero() {
+		return "is required"
+	}
+	if value.IsZero() {
+		return "" // optional fields are only checked when given
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		s := value.String()
+		length := utf8.RuneCountInString(s)
+		if min, ok := intRule(rules, "min"); ok && length < min {
+			return fmt.Sprintf("must be at least %d characters", min)
+		}
+		if max, ok := intRule(rules, "max"); ok && length > max {
+			return fmt.Sprintf("must be at most %d characters", max)
+		}
+		if _, ok := rules["email"]; ok && checkmail.ValidateFormat(s) != nil {
+			return "must be a valid email address"
+		}
+		if oneof, ok := rules["oneof"]; ok {
+			allowed := strings.Split(oneof, "|")
+			for _, a := range allowed {
+				if s == a {
+					return ""
+				}
+			}
+			return "must be one of " + strings.Join(allowed, ", ")
+		}
+
+	case reflect.Slice, reflect.Map, reflect.Array:
+		if min, ok := intRule(rules, "min"); ok && value.Len() < min {
+			return fmt.Sprintf("must have at least %d items", min)
+		}
+		if max, ok := intRule(rules, "max"); ok && value.Len() > max {
+			return fmt.Sprintf("must have at most %d items", max)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		n := number(value)
+		if min, ok := intRule(rules, "min"); ok && n < float64(min) {
+			return fmt.Sprintf("must be at least %d", min)
+		}
+		if max, ok := intRule(rules, "max"); ok && n > float64(max) {
+			return fmt.Sprintf("must be at most %d", max)
+		}
+	}
+	return ""
+}
+
+func intRule(rules map[string]string, name string) (int, bool) {
+	arg, ok := rules[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(arg)
+	return n, err == nil
+}
+
+func number(value reflect.Value) float64 {
+	switch value.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		return value.Float()
+	default:
+		return float64(value.Int())
+	}
+}